@@ -1,11 +1,17 @@
 package agent
 
 import (
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/janeczku/eventbridge/config"
+	"github.com/janeczku/eventbridge/debounce"
 	"github.com/janeczku/eventbridge/eventreceiver"
 	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/healthcheck"
+	"github.com/janeczku/eventbridge/metrics"
+	"github.com/janeczku/eventbridge/pluginrunner"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -13,7 +19,11 @@ import (
 // Agent supervises receiver and plugin components.
 type Agent struct {
 	Config    *config.Config
+	mu        sync.RWMutex // guards Config during reloads
+	reloadMu  sync.Mutex   // serializes reloads
+	writeMu   sync.RWMutex // held by doWork while writing to a snapshot of the plugin runners
 	receiver  *eventreceiver.EventReceiver
+	debouncer *debounce.Debouncer
 	quitChan  chan struct{}
 	waitGroup *sync.WaitGroup
 }
@@ -39,26 +49,87 @@ func (a *Agent) Start() error {
 		return err
 	}
 
+	return a.startPipeline(receiveChan)
+}
+
+// StartReplay starts the plugins without connecting to the event stream.
+// Events are then passed to the plugins by Replay.
+func (a *Agent) StartReplay() error {
+	log.Info("Starting agent in replay mode")
+
+	receiveChan := make(chan events.Event)
+	a.receiver = eventreceiver.New(a.Config.Agent, a.Config.EventKinds, receiveChan)
+	return a.startPipeline(receiveChan)
+}
+
+// Replay passes the events of a recording file through the receiver
+// and plugins, see EventReceiver.Replay. It returns once all events
+// have been passed to the plugin queues.
+func (a *Agent) Replay(path string, speed float64) error {
+	count, err := a.receiver.Replay(path, speed)
+	log.WithFields(log.Fields{
+		"file":   path,
+		"events": count,
+	}).Info("Replayed recorded events")
+	if err != nil {
+		return err
+	}
+
+	if a.debouncer != nil {
+		// wait for the debouncer to pass on the settled events
+		select {
+		case <-time.After(a.Config.Agent.DebounceWindowDuration + time.Second):
+		case <-a.quitChan:
+		}
+	}
+	return nil
+}
+
+// startPipeline starts the plugins and passes the events received
+// on the given channel to them.
+func (a *Agent) startPipeline(receiveChan chan events.Event) error {
 	if err := a.startPlugins(); err != nil {
 		return err
 	}
 
+	workChan := receiveChan
+	if cfg := a.Config.Agent; cfg.DebounceWindowDuration > 0 || cfg.FlapThreshold > 0 {
+		workChan = make(chan events.Event)
+		a.debouncer = debounce.New(cfg.DebounceWindowDuration, cfg.FlapThreshold, cfg.FlapWindowDuration,
+			receiveChan, workChan)
+		a.debouncer.Start()
+	}
+
 	a.waitGroup.Add(1)
-	go a.doWork(receiveChan)
+	go a.doWork(workChan)
 
 	return nil
 }
 
 // Shutdown shutdowns event receiver and plugins and stops the agent.
 func (a *Agent) Shutdown() {
+	a.shutdown(0)
+}
+
+// Drain stops the agent like Shutdown, but waits up to timeout for
+// the plugins to process their queued events.
+func (a *Agent) Drain(timeout time.Duration) {
+	a.shutdown(timeout)
+}
+
+func (a *Agent) shutdown(drain time.Duration) {
 	close(a.quitChan)
 	a.waitGroup.Wait()
 
+	if a.debouncer != nil {
+		a.debouncer.Stop()
+	}
+
 	if err := a.receiver.Stop(); err != nil {
 		log.WithField("error", err).Error("Error stopping event receiver")
 	}
 
-	if err := a.stopPlugins(); err != nil {
+	if err := a.stopPlugins(drain); err != nil {
 		log.WithField("error", err).Error("Error stopping plugin runners")
 	}
 }
@@ -72,9 +143,15 @@ func (a *Agent) startPlugins() error {
 	return nil
 }
 
-func (a *Agent) stopPlugins() error {
+func (a *Agent) stopPlugins(drain time.Duration) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	var err error
 	for _, p := range a.Config.Plugins {
+		if drain > 0 {
+			err = p.Drain(drain)
+			continue
+		}
 		err = p.Stop()
 	}
 	return err
@@ -87,11 +164,168 @@ func (a *Agent) doWork(input chan events.Event) {
 		case <-a.quitChan:
 			return
 		case ev := <-input:
-			for _, p := range a.Config.Plugins {
-				if _, ok := p.EventKinds[ev.Kind]; ok {
+			// the lock is not held while writing, so a plugin runner
+			// blocking on a full queue does not hold up a reload
+			a.writeMu.RLock()
+			a.mu.RLock()
+			a.Config.Severity.Classify(&ev)
+			runners := a.Config.Plugins
+			a.mu.RUnlock()
+			for _, p := range runners {
+				if p.Accepts(ev) {
 					p.Write(ev)
+				} else {
+					log.WithFields(log.Fields{
+						"eventId": ev.ID,
+						"plugin":  p.Name,
+					}).Debug("Event filtered")
 				}
 			}
+			a.writeMu.RUnlock()
+		}
+	}
+}
+
+// Checks reports the readiness of the event receiver and plugin runners.
+func (a *Agent) Checks() []healthcheck.Check {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var checks []healthcheck.Check
+	if a.receiver != nil {
+		stats := a.receiver.Stats()
+		lastActivity := stats.Since
+		for _, t := range []time.Time{stats.LastEvent, stats.LastPing} {
+			if t.After(lastActivity) {
+				lastActivity = t
+			}
+		}
+		idle := time.Since(lastActivity)
+
+		details := map[string]interface{}{
+			"connected":      stats.Connected,
+			"idleSeconds":    int(idle.Seconds()),
+			"reconnects":     stats.Reconnects,
+			"received":       stats.Received,
+			"maxIdleSeconds": int(a.Config.Agent.ReadyMaxIdleDuration.Seconds()),
+		}
+		if !stats.LastEvent.IsZero() {
+			details["lastEvent"] = stats.LastEvent.UTC().Format(time.RFC3339)
+		}
+		if !stats.LastPing.IsZero() {
+			details["lastPing"] = stats.LastPing.UTC().Format(time.RFC3339)
+		}
+
+		healthy := stats.Connected
+		if max := a.Config.Agent.ReadyMaxIdleDuration; max > 0 && idle > max {
+			healthy = false
+		}
+		checks = append(checks, healthcheck.Check{
+			Name:    "receiver",
+			Healthy: healthy,
+			Details: details,
+		})
+	}
+
+	for _, p := range a.Config.Plugins {
+		stats := p.Stats()
+		healthy := true
+		if max := a.Config.Agent.ReadyMaxPluginErrorRate; max > 0 && stats.ErrorRate > max {
+			healthy = false
 		}
+		checks = append(checks, healthcheck.Check{
+			Name:    "plugin:" + p.Name,
+			Healthy: healthy,
+			Details: map[string]interface{}{
+				"errorRate": stats.ErrorRate,
+				"pending":   stats.Pending,
+				"dropped":   stats.Dropped,
+				"blocked":   stats.Blocked,
+				"errors":    stats.Errors,
+				"successes": stats.Successes,
+			},
+		})
+	}
+
+	return checks
+}
+
+// Collect writes the receiver and plugin runner metrics.
+func (a *Agent) Collect(w *metrics.Writer) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.receiver != nil {
+		stats := a.receiver.Stats()
+		w.Family("eventbridge_receiver_received_total", "Total events received from the Rancher event stream.", metrics.Counter)
+		w.Sample("eventbridge_receiver_received_total", nil, float64(stats.Received))
+		kinds := make([]string, 0, len(stats.Events))
+		for kind := range stats.Events {
+			kinds = append(kinds, string(kind))
+		}
+		sort.Strings(kinds)
+		w.Family("eventbridge_receiver_events_total", "Total events passed to the plugins by kind.", metrics.Counter)
+		for _, kind := range kinds {
+			w.Sample("eventbridge_receiver_events_total", metrics.Labels{"kind": kind},
+				float64(stats.Events[events.EventKind(kind)]))
+		}
+		w.Family("eventbridge_receiver_reconnects_total", "Total reconnects to the Rancher event stream.", metrics.Counter)
+		w.Sample("eventbridge_receiver_reconnects_total", nil, float64(stats.Reconnects))
+		connected := 0.0
+		if stats.Connected {
+			connected = 1
+		}
+		w.Family("eventbridge_receiver_connected", "Whether the Rancher event stream is connected.", metrics.Gauge)
+		w.Sample("eventbridge_receiver_connected", nil, connected)
+		w.Family("eventbridge_receiver_event_latency_seconds",
+			"Delay between Rancher generating an event and the receiver receiving it.", metrics.Hist)
+		w.Histogram("eventbridge_receiver_event_latency_seconds", nil, stats.Latency.Snapshot())
+	}
+
+	stats := make([]*pluginrunner.PluginMetrics, len(a.Config.Plugins))
+	for i, p := range a.Config.Plugins {
+		stats[i] = p.Stats()
+	}
+
+	counters := []struct {
+		name, help string
+		value      func(m *pluginrunner.PluginMetrics) int
+	}{
+		{"eventbridge_plugin_events_total", "Total events queued for the plugin.",
+			func(m *pluginrunner.PluginMetrics) int { return m.Totals }},
+		{"eventbridge_plugin_successes_total", "Total events successfully processed by the plugin.",
+			func(m *pluginrunner.PluginMetrics) int { return m.Successes }},
+		{"eventbridge_plugin_errors_total", "Total failed event writes to the plugin.",
+			func(m *pluginrunner.PluginMetrics) int { return m.Errors }},
+		{"eventbridge_plugin_retries_total", "Total retried event writes to the plugin.",
+			func(m *pluginrunner.PluginMetrics) int { return m.Retries }},
+		{"eventbridge_plugin_dead_lettered_total", "Total events passed to the dead letter sink.",
+			func(m *pluginrunner.PluginMetrics) int { return m.DeadLettered }},
+		{"eventbridge_plugin_dropped_total", "Total events dropped from the plugin queue.",
+			func(m *pluginrunner.PluginMetrics) int { return m.Dropped }},
+		{"eventbridge_plugin_queue_blocked_total", "Total events that waited for room in the full plugin queue.",
+			func(m *pluginrunner.PluginMetrics) int { return m.Blocked }},
+	}
+	for _, c := range counters {
+		w.Family(c.name, c.help, metrics.Counter)
+		for i, p := range a.Config.Plugins {
+			w.Sample(c.name, metrics.Labels{"plugin": p.Name}, float64(c.value(stats[i])))
+		}
+	}
+
+	w.Family("eventbridge_plugin_error_rate", "Ratio of failed writes among the event writes of the last 5 minutes.", metrics.Gauge)
+	for i, p := range a.Config.Plugins {
+		w.Sample("eventbridge_plugin_error_rate", metrics.Labels{"plugin": p.Name}, stats[i].ErrorRate)
+	}
+
+	w.Family("eventbridge_plugin_queue_depth", "Number of events waiting in the plugin queue.", metrics.Gauge)
+	for i, p := range a.Config.Plugins {
+		w.Sample("eventbridge_plugin_queue_depth", metrics.Labels{"plugin": p.Name}, float64(stats[i].Pending))
+	}
+
+	w.Family("eventbridge_plugin_process_duration_seconds", "Duration of event writes to the plugin.", metrics.Hist)
+	for i, p := range a.Config.Plugins {
+		w.Histogram("eventbridge_plugin_process_duration_seconds", metrics.Labels{"plugin": p.Name},
+			stats[i].Latency.Snapshot())
 	}
 }
@@ -0,0 +1,200 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/janeczku/eventbridge/config"
+	"github.com/janeczku/eventbridge/pluginrunner"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Maximum time to wait for a removed or changed plugin runner to process its queue.
+const drainTimeout = 30 * time.Second
+
+// Reload applies a new configuration without reconnecting to the event stream.
+// Plugin runners whose configuration is unchanged keep running. New and changed
+// runners are started before the configuration is switched, so a runner that
+// fails to start leaves the running configuration untouched. Runners that were
+// removed or changed are drained and stopped afterwards while events keep flowing.
+// A changed runner that reuses the disk queue of its predecessor can only be
+// started once the predecessor is stopped; until then events go to the predecessor.
+// Changes to the [agent] section other than the log level require a restart.
+func (a *Agent) Reload(newConfig *config.Config) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	a.mu.RLock()
+	oldConfig := a.Config
+	a.mu.RUnlock()
+	if len(newConfig.Plugins) == 0 {
+		return fmt.Errorf("No plugins configured")
+	}
+
+	changed := changedPlugins(oldConfig, newConfig)
+
+	oldRunners := make(map[string]*pluginrunner.PluginRunner)
+	for _, p := range oldConfig.Plugins {
+		oldRunners[p.Name] = p
+	}
+
+	// runners is the final set, active the set used until the handovers are done
+	var runners, active, started, handovers []*pluginrunner.PluginRunner
+	for _, p := range newConfig.Plugins {
+		old, ok := oldRunners[p.Name]
+		switch {
+		case ok && !changed[p.Name]:
+			runners = append(runners, old)
+			active = append(active, old)
+		case ok && p.QueueDir != "" && p.QueueDir == old.QueueDir:
+			runners = append(runners, p)
+			active = append(active, old)
+			handovers = append(handovers, p)
+		default:
+			runners = append(runners, p)
+			active = append(active, p)
+			started = append(started, p)
+		}
+	}
+
+	if err := newConfig.ResolveDeadLetterPlugins(append(started, handovers...), runners); err != nil {
+		return err
+	}
+
+	for i, p := range started {
+		if err := p.Start(); err != nil {
+			for _, s := range started[:i] {
+				s.Stop()
+			}
+			return fmt.Errorf("Could not start plugin '%s': %v", p.Name, err)
+		}
+	}
+
+	a.mu.Lock()
+	logLevel := newConfig.Agent.LogLevel
+	if logLevel != oldConfig.Agent.LogLevel {
+		if level, err := log.ParseLevel(logLevel); err == nil {
+			log.WithField("logLevel", logLevel).Info("Setting log level")
+			log.SetLevel(level)
+		}
+	}
+	newConfig.Agent.LogLevel = oldConfig.Agent.LogLevel
+	if *newConfig.Agent != *oldConfig.Agent {
+		log.Warn("Changes to the [agent] config section other than the log level require a restart")
+	}
+	oldConfig.Agent.LogLevel = logLevel
+	newConfig.Agent = oldConfig.Agent
+
+	newConfig.Plugins = active
+	a.Config = newConfig
+	a.receiver.SetEventKinds(newConfig.EventKinds)
+	a.mu.Unlock()
+
+	// wait until events are no longer written to the removed runners
+	a.writeMu.Lock()
+	a.writeMu.Unlock()
+
+	for name, old := range oldRunners {
+		if containsRunner(active, old) {
+			continue
+		}
+		log.WithField("plugin", name).Info("Draining plugin runner")
+		if err := old.Drain(drainTimeout); err != nil {
+			log.WithFields(log.Fields{
+				"error":  err,
+				"plugin": name,
+			}).Error("Error stopping plugin runner")
+		}
+	}
+
+	var handoverErr error
+	for _, p := range handovers {
+		if err := a.handover(oldRunners[p.Name], p); err != nil {
+			handoverErr = err
+		}
+	}
+
+	log.WithField("plugins", newConfig.PluginNames()).Info("Configuration reloaded")
+	return handoverErr
+}
+
+// handover drains the predecessor of a changed plugin runner that uses the
+// same disk queue, stops it and starts the new runner in its place. If the
+// new runner fails to start, the plugin is removed from the configuration.
+// The runners are switched outside the lock, events written to the stopped
+// predecessor in the meantime are dropped.
+func (a *Agent) handover(old, p *pluginrunner.PluginRunner) error {
+	log.WithField("plugin", p.Name).Info("Draining plugin runner")
+	old.WaitDrained(drainTimeout)
+
+	if err := old.Stop(); err != nil {
+		log.WithFields(log.Fields{
+			"error":  err,
+			"plugin": p.Name,
+		}).Error("Error stopping plugin runner")
+	}
+	err := p.Start()
+
+	// doWork may still iterate over the current list
+	a.mu.Lock()
+	var plugins []*pluginrunner.PluginRunner
+	for _, r := range a.Config.Plugins {
+		switch {
+		case r != old:
+			plugins = append(plugins, r)
+		case err == nil:
+			plugins = append(plugins, p)
+		}
+	}
+	a.Config.Plugins = plugins
+	a.mu.Unlock()
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err,
+			"plugin": p.Name,
+		}).Error("Error starting plugin runner")
+		return fmt.Errorf("Could not start plugin '%s': %v", p.Name, err)
+	}
+	return nil
+}
+
+// changedPlugins returns the names of the plugins whose configuration differs
+// between the configs, including plugins whose dead letter plugin changed.
+func changedPlugins(oldConfig, newConfig *config.Config) map[string]bool {
+	oldNames := make(map[string]bool)
+	for _, p := range oldConfig.Plugins {
+		oldNames[p.Name] = true
+	}
+
+	changed := make(map[string]bool)
+	for _, p := range newConfig.Plugins {
+		if !oldNames[p.Name] || oldConfig.Fingerprint(p.Name) != newConfig.Fingerprint(p.Name) {
+			changed[p.Name] = true
+		}
+	}
+
+	for {
+		updated := false
+		for _, p := range newConfig.Plugins {
+			target := newConfig.DeadLetterPlugin(p.Name)
+			if len(target) > 0 && changed[target] && !changed[p.Name] {
+				changed[p.Name] = true
+				updated = true
+			}
+		}
+		if !updated {
+			return changed
+		}
+	}
+}
+
+func containsRunner(runners []*pluginrunner.PluginRunner, runner *pluginrunner.PluginRunner) bool {
+	for _, p := range runners {
+		if p == runner {
+			return true
+		}
+	}
+	return false
+}
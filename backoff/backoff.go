@@ -0,0 +1,26 @@
+// Package backoff computes randomized exponential delays between attempts.
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Exponential returns the delay before the given attempt (starting at 1).
+// The delay starts at initial, doubles with every attempt up to max (if max
+// is positive) and is randomized between half and the full value to spread
+// out attempts.
+func Exponential(attempt int, initial, max time.Duration) time.Duration {
+	d := initial
+	for i := 1; i < attempt && (max <= 0 || d < max) && d < time.Duration(1<<62); i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
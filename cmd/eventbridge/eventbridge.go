@@ -43,6 +43,19 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:      "replay",
+			Usage:     "pass the events of a recording file to the configured plugins",
+			ArgsUsage: "<recording file>",
+			Flags: []cli.Flag{
+				cli.Float64Flag{
+					Name:  "speed",
+					Value: 1,
+					Usage: "replay speed: 1 for real time, 10 for ten times faster, 0 for as fast as possible",
+				},
+			},
+			Action: runReplay,
+		},
 	}
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
@@ -59,13 +72,17 @@ func main() {
 	app.Run(os.Args)
 }
 
-func runApp(c *cli.Context) error {
-	if len(c.String("config")) == 0 {
+// loadConfig loads the config file given with the '--config' flag
+// and applies the log level. With memoryQueues set, plugins configured
+// with a disk queue use a memory queue instead.
+func loadConfig(configPath, logLevel string, memoryQueues bool) *config.Config {
+	if len(configPath) == 0 {
 		log.Fatalln("'--config' flag is required")
 	}
 
 	conf := config.New()
-	err := conf.LoadConfig(c.String("config"))
+	conf.MemoryQueues = memoryQueues
+	err := conf.LoadConfig(configPath)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -74,8 +91,8 @@ func runApp(c *cli.Context) error {
 		log.Fatal("Error: No plugins configured")
 	}
 
-	if c.IsSet("loglevel") {
-		conf.Agent.LogLevel = c.String("loglevel")
+	if len(logLevel) > 0 {
+		conf.Agent.LogLevel = logLevel
 	}
 
 	log.Infof("Starting Eventbridge version %s (%s)", Version, GitCommit)
@@ -85,6 +102,15 @@ func runApp(c *cli.Context) error {
 		log.WithField("logLevel", conf.Agent.LogLevel).Info("Setting log level")
 		log.SetLevel(level)
 	}
+	return conf
+}
+
+func runApp(c *cli.Context) error {
+	var logLevel string
+	if c.IsSet("loglevel") {
+		logLevel = c.String("loglevel")
+	}
+	conf := loadConfig(c.String("config"), logLevel, false)
 
 	a, err := agent.New(conf)
 	if err != nil {
@@ -96,24 +122,48 @@ func runApp(c *cli.Context) error {
 		log.Fatal(err)
 	}
 
-	signalChan := make(chan os.Signal)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	reload := func() error {
+		newConf := config.New()
+		if err := newConf.LoadConfig(c.String("config")); err != nil {
+			return err
+		}
+		if c.IsSet("loglevel") {
+			newConf.Agent.LogLevel = c.String("loglevel")
+		}
+		return a.Reload(newConf)
+	}
+
+	var adminReload func() error
+	if conf.Agent.AdminEndpoint {
+		adminReload = reload
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	errorChan := make(chan error)
 	go func(c chan error) {
-		err := healthcheck.StartHealthCheck(conf.Agent.HealthCheckPort)
+		err := healthcheck.StartHealthCheck(conf.Agent.HealthCheckPort, a, a, adminReload)
 		c <- err
 	}(errorChan)
 
-	select {
-	case e := <-errorChan:
-		log.Errorf("Healthcheck exited with error: %v", e)
-		break
-	case s := <-signalChan:
-		log.Infof("Application exit requested by signal: %s", s.String())
-		break
+	for {
+		select {
+		case e := <-errorChan:
+			log.Errorf("Healthcheck exited with error: %v", e)
+			a.Shutdown()
+			return nil
+		case s := <-signalChan:
+			if s == syscall.SIGHUP {
+				log.Info("Reloading configuration")
+				if err := reload(); err != nil {
+					log.Errorf("Failed to reload configuration: %v", err)
+				}
+				continue
+			}
+			log.Infof("Application exit requested by signal: %s", s.String())
+			a.Shutdown()
+			return nil
+		}
 	}
-
-	a.Shutdown()
-	return nil
 }
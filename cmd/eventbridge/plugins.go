@@ -2,4 +2,5 @@ package main
 
 import (
 	_ "github.com/janeczku/eventbridge/plugins/slack"
+	_ "github.com/janeczku/eventbridge/plugins/webhook"
 )
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+
+	"github.com/janeczku/eventbridge/agent"
+)
+
+// Maximum time to wait for the plugins to process the replayed events.
+const replayDrainTimeout = time.Minute
+
+func runReplay(c *cli.Context) error {
+	if !c.Args().Present() {
+		log.Fatalln("Recording file argument is required")
+	}
+	path := c.Args().First()
+
+	speed := c.Float64("speed")
+	if speed < 0 {
+		log.Fatalln("'--speed' must not be negative")
+	}
+
+	var logLevel string
+	if c.GlobalIsSet("loglevel") {
+		logLevel = c.GlobalString("loglevel")
+	}
+	// the disk queues may be in use by the daemon
+	conf := loadConfig(c.GlobalString("config"), logLevel, true)
+
+	a, err := agent.New(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := a.StartReplay(); err != nil {
+		log.Fatal(err)
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+
+	doneChan := make(chan error, 1)
+	go func() {
+		doneChan <- a.Replay(path, speed)
+	}()
+
+	select {
+	case err := <-doneChan:
+		if err != nil {
+			log.Errorf("Replay failed: %v", err)
+		}
+		a.Drain(replayDrainTimeout)
+	case s := <-signalChan:
+		log.Infof("Application exit requested by signal: %s", s.String())
+		a.Shutdown()
+	}
+	return nil
+}
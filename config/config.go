@@ -1,13 +1,20 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/filter"
 	"github.com/janeczku/eventbridge/pluginrunner"
 	"github.com/janeczku/eventbridge/plugins"
+	"github.com/janeczku/eventbridge/severity"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/bbangert/toml"
@@ -17,6 +24,16 @@ type Config struct {
 	Agent      *AgentConfig
 	Plugins    []*pluginrunner.PluginRunner
 	EventKinds map[events.EventKind]bool
+	Severity   *severity.Classifier
+
+	// Use memory queues for all plugins, so that the disk queues of
+	// a running instance are not touched, e.g. when replaying events.
+	MemoryQueues bool
+
+	// dead letter plugin names by plugin runner name
+	deadLetterPlugins map[string]string
+	// serialized plugin sections by plugin runner name
+	fingerprints map[string]string
 }
 
 type AgentConfig struct {
@@ -25,8 +42,66 @@ type AgentConfig struct {
 	RancherURL         string `toml:"rancher_url"`
 	EventReceiverCount int    `toml:"event_receiver_count"`
 	EventQueueLimit    int    `toml:"event_queue_limit"`
+	QueueDir           string `toml:"queue_dir"`
 	HealthCheckPort    int    `toml:"health_check_port"`
+	AdminEndpoint      bool   `toml:"admin_endpoint"`
 	LogLevel           string `toml:"loglevel"`
+
+	// Readiness thresholds
+	ReadyMaxIdle            string  `toml:"ready_max_idle"`
+	ReadyMaxPluginErrorRate float64 `toml:"ready_max_plugin_error_rate"`
+
+	// Event stream reconnects
+	ReconnectMaxBackoff  string `toml:"reconnect_max_backoff"`
+	ReconnectIdleTimeout string `toml:"reconnect_idle_timeout"`
+	ResyncOnReconnect    bool   `toml:"resync_on_reconnect"`
+
+	// Recording of the event stream for the replay command
+	RecordFile string `toml:"record_file"`
+
+	// Enrichment from the Rancher API
+	EnrichEvents   bool   `toml:"enrich_events"`
+	EnrichCacheTTL string `toml:"enrich_cache_ttl"`
+	EnrichTimeout  string `toml:"enrich_timeout"`
+
+	// Debouncing and flap detection
+	DebounceWindow string `toml:"debounce_window"`
+	FlapThreshold  int    `toml:"flap_threshold"`
+	FlapWindow     string `toml:"flap_window"`
+
+	ReadyMaxIdleDuration         time.Duration `toml:"-"`
+	ReconnectMaxBackoffDuration  time.Duration `toml:"-"`
+	ReconnectIdleTimeoutDuration time.Duration `toml:"-"`
+	EnrichCacheTTLDuration       time.Duration `toml:"-"`
+	EnrichTimeoutDuration        time.Duration `toml:"-"`
+	DebounceWindowDuration       time.Duration `toml:"-"`
+	FlapWindowDuration           time.Duration `toml:"-"`
+}
+
+// RunnerConfig holds the plugin runner settings that are accepted
+// in every plugin section.
+type RunnerConfig struct {
+	Filter    string `toml:"filter"`
+	Queue     string `toml:"queue"`
+	QueueDir  string `toml:"queue_dir"`
+	QueueSync string `toml:"queue_sync"`
+
+	QueueOverflow     string `toml:"queue_overflow"`
+	QueueBlockTimeout string `toml:"queue_block_timeout"`
+
+	RetryMaxAttempts    int    `toml:"retry_max_attempts"`
+	RetryInitialBackoff string `toml:"retry_initial_backoff"`
+	RetryMaxBackoff     string `toml:"retry_max_backoff"`
+	DeadLetterFile      string `toml:"dead_letter_file"`
+	DeadLetterPlugin    string `toml:"dead_letter_plugin"`
+
+	Workers int `toml:"workers"`
+
+	BatchSize    int    `toml:"batch_size"`
+	BatchTimeout string `toml:"batch_timeout"`
+
+	DigestInterval  string `toml:"digest_interval"`
+	DigestMaxEvents int    `toml:"digest_max_events"`
 }
 
 // New initializes a new config object with defaults.
@@ -35,11 +110,25 @@ func New() *Config {
 		Agent: &AgentConfig{
 			EventReceiverCount: 5,
 			EventQueueLimit:    50,
+			QueueDir:           "/var/lib/eventbridge/queue",
 			HealthCheckPort:    10240,
 			LogLevel:           "info",
+
+			ReadyMaxIdle:            "2m",
+			ReadyMaxPluginErrorRate: 0.5,
+
+			ReconnectMaxBackoff:  "1m",
+			ReconnectIdleTimeout: "0s",
+
+			EnrichCacheTTL: "5m",
+			EnrichTimeout:  "2s",
+			DebounceWindow: "0s",
+			FlapWindow:     "5m",
 		},
-		Plugins:    make([]*pluginrunner.PluginRunner, 0),
-		EventKinds: make(map[events.EventKind]bool),
+		Plugins:           make([]*pluginrunner.PluginRunner, 0),
+		EventKinds:        make(map[events.EventKind]bool),
+		deadLetterPlugins: make(map[string]string),
+		fingerprints:      make(map[string]string),
 	}
 	return c
 }
@@ -67,35 +156,202 @@ func (c *Config) LoadConfig(configPath string) error {
 		return fmt.Errorf("Error parsing [agent] config: %v", err)
 	}
 
+	if c.Agent.ReadyMaxIdleDuration, err = time.ParseDuration(c.Agent.ReadyMaxIdle); err != nil {
+		return fmt.Errorf("Error parsing [agent] config: Invalid ready_max_idle: %v", err)
+	}
+	if c.Agent.ReconnectMaxBackoffDuration, err = time.ParseDuration(c.Agent.ReconnectMaxBackoff); err != nil {
+		return fmt.Errorf("Error parsing [agent] config: Invalid reconnect_max_backoff: %v", err)
+	}
+	if c.Agent.ReconnectIdleTimeoutDuration, err = time.ParseDuration(c.Agent.ReconnectIdleTimeout); err != nil {
+		return fmt.Errorf("Error parsing [agent] config: Invalid reconnect_idle_timeout: %v", err)
+	}
+	if c.Agent.EnrichCacheTTLDuration, err = time.ParseDuration(c.Agent.EnrichCacheTTL); err != nil {
+		return fmt.Errorf("Error parsing [agent] config: Invalid enrich_cache_ttl: %v", err)
+	}
+	if c.Agent.EnrichTimeoutDuration, err = time.ParseDuration(c.Agent.EnrichTimeout); err != nil {
+		return fmt.Errorf("Error parsing [agent] config: Invalid enrich_timeout: %v", err)
+	}
+	if c.Agent.DebounceWindowDuration, err = time.ParseDuration(c.Agent.DebounceWindow); err != nil {
+		return fmt.Errorf("Error parsing [agent] config: Invalid debounce_window: %v", err)
+	}
+	if c.Agent.FlapWindowDuration, err = time.ParseDuration(c.Agent.FlapWindow); err != nil {
+		return fmt.Errorf("Error parsing [agent] config: Invalid flap_window: %v", err)
+	}
+
 	delete(configFile, "agent")
 
+	// Severity rules
+	var rules []severity.RuleConfig
+	if section, ok := configFile["severity"]; ok {
+		if err = toml.PrimitiveDecode(section, &rules); err != nil {
+			return fmt.Errorf("Error parsing [[severity]] config: %v", err)
+		}
+		delete(configFile, "severity")
+	}
+	if c.Severity, err = severity.New(rules); err != nil {
+		return fmt.Errorf("Error parsing [[severity]] config: %v", err)
+	}
+
 	// Plugin configs
-	for pluginName, pluginConf := range configFile {
-		if err = c.addPlugin(pluginName, pluginConf); err != nil {
-			return fmt.Errorf("Error parsing [%s] config: %v", pluginName, err)
+	sections, err := pluginSections(configFile)
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		if err = c.addPlugin(section.pluginType, section.name, section.config); err != nil {
+			return fmt.Errorf("Error parsing [%s] config: %v", section.name, err)
 		}
 	}
 
-	for _, kinds := range plugins.PluginEventKinds {
-		for _, kind := range kinds {
-			if _, ok := c.EventKinds[kind]; !ok {
-				c.EventKinds[kind] = true
-			}
+	if err = c.ResolveDeadLetterPlugins(c.Plugins, c.Plugins); err != nil {
+		return err
+	}
+
+	for _, p := range c.Plugins {
+		for kind := range p.EventKinds {
+			c.EventKinds[kind] = true
 		}
 	}
 
 	return nil
 }
 
+// Fingerprint returns a serialized form of the plugin section of the given
+// plugin runner. Sections with equal fingerprints have identical settings.
+func (c *Config) Fingerprint(name string) string {
+	return c.fingerprints[name]
+}
+
+// DeadLetterPlugin returns the name of the dead letter plugin configured
+// for the given plugin runner or an empty string.
+func (c *Config) DeadLetterPlugin(name string) string {
+	return c.deadLetterPlugins[name]
+}
+
 // PluginNames returns a list of human-friendly names of all configured plugins.
 func (c *Config) PluginNames() []string {
 	var names []string
 	for _, p := range c.Plugins {
+		if p.Name != p.Type {
+			names = append(names, fmt.Sprintf("%s (%s)", p.Plugin.Name(), p.Name))
+			continue
+		}
 		names = append(names, p.Plugin.Name())
 	}
 	return names
 }
 
+// pluginSection is the config of a single plugin instance.
+type pluginSection struct {
+	pluginType string
+	name       string
+	config     toml.Primitive
+}
+
+// pluginSections returns the plugin instances declared in the config file.
+// A plugin can be configured in three ways:
+//
+//	[slack]            a single instance named "slack"
+//	[slack.ops]        an instance named "slack.ops"
+//	[[outputs.slack]]  an instance named "slack.<name>", where <name> is
+//	                   taken from the 'name' key or the position in the list
+func pluginSections(configFile map[string]toml.Primitive) ([]pluginSection, error) {
+	var sections []pluginSection
+	seen := make(map[string]bool)
+	add := func(section pluginSection) error {
+		if seen[section.name] {
+			return fmt.Errorf("Duplicate plugin instance '%s'", section.name)
+		}
+		seen[section.name] = true
+		sections = append(sections, section)
+		return nil
+	}
+
+	var keys []string
+	for key := range configFile {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "outputs" {
+			var outputs map[string][]toml.Primitive
+			if err := toml.PrimitiveDecode(configFile[key], &outputs); err != nil {
+				return nil, fmt.Errorf("Error parsing [outputs] config: %v", err)
+			}
+			var types []string
+			for pluginType := range outputs {
+				types = append(types, pluginType)
+			}
+			sort.Strings(types)
+			for _, pluginType := range types {
+				for i, conf := range outputs[pluginType] {
+					var instance struct {
+						Name string `toml:"name"`
+					}
+					if err := toml.PrimitiveDecode(conf, &instance); err != nil {
+						return nil, fmt.Errorf("Error parsing [[outputs.%s]] config: %v", pluginType, err)
+					}
+					if len(instance.Name) == 0 {
+						instance.Name = fmt.Sprintf("%d", i+1)
+					}
+					if err := add(pluginSection{pluginType, pluginType + "." + instance.Name, conf}); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+
+		instances, err := namedInstances(configFile[key])
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing [%s] config: %v", key, err)
+		}
+		if len(instances) == 0 {
+			if err := add(pluginSection{key, key, configFile[key]}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var names []string
+		for name := range instances {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := add(pluginSection{key, key + "." + name, instances[name]}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sections, nil
+}
+
+// namedInstances returns the sub-sections of a plugin section if it
+// consists of sub-sections only, e.g. [slack.ops] and [slack.dev].
+func namedInstances(section toml.Primitive) (map[string]toml.Primitive, error) {
+	var values map[string]interface{}
+	if err := toml.PrimitiveDecode(section, &values); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	for _, v := range values {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return nil, nil
+		}
+	}
+
+	var instances map[string]toml.Primitive
+	if err := toml.PrimitiveDecode(section, &instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
 func replaceEnvsFile(path string) (string, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -110,21 +366,34 @@ func replaceEnvsFile(path string) (string, error) {
 	return contents, nil
 }
 
-func (c *Config) addPlugin(name string, config toml.Primitive) error {
-	factory, ok := plugins.RegisteredPlugins[name]
+func (c *Config) addPlugin(pluginType, name string, config toml.Primitive) error {
+	factory, ok := plugins.RegisteredPlugins[pluginType]
 	if !ok {
-		return fmt.Errorf("Unknown plugin '%s'", name)
+		return fmt.Errorf("Unknown plugin '%s'", pluginType)
 	}
 
 	plugin := factory()
 
+	var section map[string]interface{}
+	if err := toml.PrimitiveDecode(config, &section); err != nil {
+		return fmt.Errorf("Could not parse config for plugin '%s': %v", name, err)
+	}
+	fingerprint, err := json.Marshal(section)
+	if err != nil {
+		return fmt.Errorf("Could not parse config for plugin '%s': %v", name, err)
+	}
+	c.fingerprints[name] = string(fingerprint)
+
 	if err := toml.PrimitiveDecode(config, plugin); err != nil {
 		return fmt.Errorf("Could not parse config for plugin '%s': %v", name, err)
 	}
+	if namer, ok := plugin.(plugins.InstanceNamer); ok {
+		namer.SetInstanceName(name)
+	}
 
-	eventKinds, ok := plugins.PluginEventKinds[name]
+	eventKinds, ok := plugins.PluginEventKinds[pluginType]
 	if !ok {
-		return fmt.Errorf("No event kinds defined for plugin '%s'", name)
+		return fmt.Errorf("No event kinds defined for plugin '%s'", pluginType)
 	}
 
 	m := make(map[events.EventKind]bool)
@@ -132,9 +401,248 @@ func (c *Config) addPlugin(name string, config toml.Primitive) error {
 		m[kind] = true
 	}
 
-	runner := pluginrunner.New(name, plugin, c.Agent.EventQueueLimit, m)
+	runnerConfig := &RunnerConfig{}
+	if err := toml.PrimitiveDecode(config, runnerConfig); err != nil {
+		return fmt.Errorf("Could not parse runner config for plugin '%s': %v", name, err)
+	}
+	if c.MemoryQueues && runnerConfig.Queue == "disk" {
+		runnerConfig.Queue = "memory"
+	}
+
+	queue, err := c.newQueue(name, runnerConfig)
+	if err != nil {
+		return err
+	}
+
+	runner := pluginrunner.New(name, plugin, queue, m)
+	runner.Type = pluginType
+	runner.QueueDir = c.diskQueueDir(name, runnerConfig)
+	if len(runnerConfig.Filter) > 0 {
+		f, err := filter.Compile(runnerConfig.Filter)
+		if err != nil {
+			return err
+		}
+		runner.Filter = f
+		log.WithFields(log.Fields{
+			"pluginName": name,
+			"filter":     f.String(),
+		}).Debug("Added plugin filter")
+	}
+	if err := c.configureRetry(runner, runnerConfig); err != nil {
+		return err
+	}
+	if err := configureBatching(name, runner, runnerConfig); err != nil {
+		return err
+	}
+	if err := configureDigest(name, runner, runnerConfig); err != nil {
+		return err
+	}
+	if runnerConfig.Workers > 0 {
+		runner.WorkerCount = runnerConfig.Workers
+	}
+
 	log.WithField("pluginName", name).Debug("Added plugin runner")
 	c.Plugins = append(c.Plugins, runner)
 
 	return nil
 }
+
+// diskQueueDir returns the directory of the plugin's disk queue or an
+// empty string if the plugin uses a memory queue.
+func (c *Config) diskQueueDir(name string, runnerConfig *RunnerConfig) string {
+	if runnerConfig.Queue != "disk" {
+		return ""
+	}
+	if len(runnerConfig.QueueDir) > 0 {
+		return runnerConfig.QueueDir
+	}
+	return filepath.Join(c.Agent.QueueDir, name)
+}
+
+func (c *Config) newQueue(name string, runnerConfig *RunnerConfig) (pluginrunner.QueueFactory, error) {
+	limit := c.Agent.EventQueueLimit
+	overflow, err := pluginrunner.ParseOverflowPolicy(runnerConfig.QueueOverflow)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid queue_overflow for plugin '%s': %v", name, err)
+	}
+	blockTimeout := pluginrunner.DefaultBlockTimeout
+	if len(runnerConfig.QueueBlockTimeout) > 0 {
+		if blockTimeout, err = time.ParseDuration(runnerConfig.QueueBlockTimeout); err != nil {
+			return nil, fmt.Errorf("Invalid queue_block_timeout for plugin '%s': %v", name, err)
+		}
+	}
+
+	switch runnerConfig.Queue {
+	case "", "memory":
+		return func() (pluginrunner.Queue, error) {
+			queue := pluginrunner.NewEventQueue(limit)
+			queue.Overflow = overflow
+			queue.BlockTimeout = blockTimeout
+			return queue, nil
+		}, nil
+	case "disk":
+		if overflow == pluginrunner.DropPriority {
+			return nil, fmt.Errorf("Queue overflow policy '%s' is not supported by disk queues (plugin '%s')", overflow, name)
+		}
+		dir := c.diskQueueDir(name, runnerConfig)
+		policy := pluginrunner.SyncPolicy(runnerConfig.QueueSync)
+		return func() (pluginrunner.Queue, error) {
+			queue, err := pluginrunner.NewDiskQueue(dir, limit, policy)
+			if err != nil {
+				return nil, fmt.Errorf("Could not open disk queue for plugin '%s': %v", name, err)
+			}
+			queue.Overflow = overflow
+			queue.BlockTimeout = blockTimeout
+			log.WithFields(log.Fields{
+				"pluginName": name,
+				"queueDir":   dir,
+			}).Debug("Using disk queue")
+			return queue, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("Unknown queue type '%s' for plugin '%s'", runnerConfig.Queue, name)
+}
+
+func configureBatching(name string, runner *pluginrunner.PluginRunner, runnerConfig *RunnerConfig) error {
+	if runnerConfig.BatchSize == 0 && len(runnerConfig.BatchTimeout) == 0 {
+		return nil
+	}
+	if _, ok := runner.Plugin.(plugins.BatchProcessor); !ok {
+		return fmt.Errorf("Plugin '%s' does not support batching", name)
+	}
+	runner.BatchSize = runnerConfig.BatchSize
+	if len(runnerConfig.BatchTimeout) > 0 {
+		d, err := time.ParseDuration(runnerConfig.BatchTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid batch_timeout for plugin '%s': %v", name, err)
+		}
+		runner.BatchTimeout = d
+	}
+	return nil
+}
+
+// configureDigest enables the digest mode of the runner if configured.
+func configureDigest(name string, runner *pluginrunner.PluginRunner, runnerConfig *RunnerConfig) error {
+	if len(runnerConfig.DigestInterval) == 0 && runnerConfig.DigestMaxEvents == 0 {
+		return nil
+	}
+	if _, ok := runner.Plugin.(plugins.DigestProcessor); !ok {
+		return fmt.Errorf("Plugin '%s' does not support the digest mode", name)
+	}
+	if len(runnerConfig.DigestInterval) > 0 {
+		d, err := time.ParseDuration(runnerConfig.DigestInterval)
+		if err != nil {
+			return fmt.Errorf("Invalid digest_interval for plugin '%s': %v", name, err)
+		}
+		runner.DigestInterval = d
+	}
+	if runner.DigestInterval <= 0 && runnerConfig.DigestMaxEvents <= 0 {
+		return fmt.Errorf("Plugin '%s' requires a positive digest_interval or digest_max_events", name)
+	}
+	if runner.BatchSize > 1 {
+		return fmt.Errorf("Plugin '%s' cannot use batching and the digest mode at the same time", name)
+	}
+	runner.DigestMaxEvents = runnerConfig.DigestMaxEvents
+	log.WithFields(log.Fields{
+		"pluginName": name,
+		"interval":   runner.DigestInterval,
+		"maxEvents":  runner.DigestMaxEvents,
+	}).Debug("Using digest mode")
+	return nil
+}
+
+func (c *Config) configureRetry(runner *pluginrunner.PluginRunner, runnerConfig *RunnerConfig) error {
+	if runnerConfig.RetryMaxAttempts > 0 {
+		runner.Retry.MaxAttempts = runnerConfig.RetryMaxAttempts
+	}
+
+	if len(runnerConfig.RetryInitialBackoff) > 0 {
+		d, err := time.ParseDuration(runnerConfig.RetryInitialBackoff)
+		if err != nil {
+			return fmt.Errorf("Invalid retry_initial_backoff: %v", err)
+		}
+		runner.Retry.InitialBackoff = d
+	}
+
+	if len(runnerConfig.RetryMaxBackoff) > 0 {
+		d, err := time.ParseDuration(runnerConfig.RetryMaxBackoff)
+		if err != nil {
+			return fmt.Errorf("Invalid retry_max_backoff: %v", err)
+		}
+		runner.Retry.MaxBackoff = d
+	}
+
+	if len(runnerConfig.DeadLetterFile) > 0 && len(runnerConfig.DeadLetterPlugin) > 0 {
+		return fmt.Errorf("Only one of dead_letter_file and dead_letter_plugin may be set")
+	}
+
+	if len(runnerConfig.DeadLetterFile) > 0 {
+		runner.DeadLetter = pluginrunner.NewFileSink(runnerConfig.DeadLetterFile)
+	}
+
+	if len(runnerConfig.DeadLetterPlugin) > 0 {
+		if runnerConfig.DeadLetterPlugin == runner.Name {
+			return fmt.Errorf("Plugin cannot be its own dead letter plugin")
+		}
+		c.deadLetterPlugins[runner.Name] = runnerConfig.DeadLetterPlugin
+	}
+
+	return nil
+}
+
+// ResolveDeadLetterPlugins connects the given plugin runners to the
+// runners among targets configured as their dead letter destination.
+func (c *Config) ResolveDeadLetterPlugins(runners, targets []*pluginrunner.PluginRunner) error {
+	if err := c.checkDeadLetterCycles(); err != nil {
+		return err
+	}
+
+	targetsByName := make(map[string]*pluginrunner.PluginRunner)
+	for _, p := range targets {
+		targetsByName[p.Name] = p
+	}
+
+	for _, runner := range runners {
+		name := runner.Name
+		target, ok := c.deadLetterPlugins[name]
+		if !ok {
+			continue
+		}
+		targetRunner, ok := targetsByName[target]
+		if !ok {
+			return fmt.Errorf("Error parsing [%s] config: Unknown dead letter plugin '%s'", name, target)
+		}
+		runner.DeadLetter = targetRunner
+	}
+
+	return nil
+}
+
+// checkDeadLetterCycles rejects dead letter plugins that lead back to the
+// plugin, e.g. A -> B -> A, as events failing in all of them would be
+// passed around forever.
+func (c *Config) checkDeadLetterCycles() error {
+	var names []string
+	for name := range c.deadLetterPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := []string{name}
+		seen := map[string]bool{name: true}
+		for next, ok := c.deadLetterPlugins[name]; ok; next, ok = c.deadLetterPlugins[next] {
+			path = append(path, next)
+			if next == name {
+				return fmt.Errorf("Error parsing [%s] config: Dead letter plugins form a cycle: %s",
+					name, strings.Join(path, " -> "))
+			}
+			if seen[next] {
+				// a cycle not including this plugin, reported for its members
+				break
+			}
+			seen[next] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package debounce provides a pipeline stage that settles bursts of
+// events per resource and detects flapping resources.
+package debounce
+
+import (
+	"sync"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Interval at which settled events are flushed.
+const tickInterval = 250 * time.Millisecond
+
+// resource tracks the events of a single resource.
+type resource struct {
+	pending  *events.Event // merged event waiting to settle
+	deadline time.Time     // time the pending event settles
+	changes  []time.Time   // times of the recent transitions
+	flapping bool
+}
+
+// Debouncer passes events from its input to its output channel.
+// Events of the same resource received within the window are merged
+// and only the settled state is passed on once the resource has been
+// quiet for the window. A resource with at least FlapThreshold
+// transitions within FlapWindow is flapping: a single event with
+// Transition.Flapping set is passed on and further events are held
+// back until the resource calms down.
+type Debouncer struct {
+	Window        time.Duration
+	FlapThreshold int
+	FlapWindow    time.Duration
+
+	input     <-chan events.Event
+	output    chan<- events.Event
+	resources map[string]*resource
+	quitChan  chan struct{}
+	waitGroup *sync.WaitGroup
+}
+
+func New(window time.Duration, flapThreshold int, flapWindow time.Duration, input <-chan events.Event, output chan<- events.Event) *Debouncer {
+	return &Debouncer{
+		Window:        window,
+		FlapThreshold: flapThreshold,
+		FlapWindow:    flapWindow,
+		input:         input,
+		output:        output,
+		resources:     make(map[string]*resource),
+		quitChan:      make(chan struct{}),
+		waitGroup:     &sync.WaitGroup{},
+	}
+}
+
+// Start dispatches the debounce routine.
+func (d *Debouncer) Start() {
+	d.waitGroup.Add(1)
+	go d.doWork()
+}
+
+// Stop stops the debounce routine. Pending events are discarded.
+func (d *Debouncer) Stop() {
+	close(d.quitChan)
+	d.waitGroup.Wait()
+}
+
+func (d *Debouncer) doWork() {
+	defer d.waitGroup.Done()
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.quitChan:
+			return
+		case ev := <-d.input:
+			d.add(ev, time.Now())
+		case now := <-ticker.C:
+			d.flush(now)
+		}
+	}
+}
+
+func (d *Debouncer) add(ev events.Event, now time.Time) {
+	key := string(ev.Kind) + "/" + ev.GetResourceID()
+	res, ok := d.resources[key]
+	if !ok {
+		res = &resource{}
+		d.resources[key] = res
+	}
+
+	if !ev.Transition.Initial && (ev.Transition.StateChanged || ev.Transition.HealthChanged) {
+		res.changes = append(d.recent(res.changes, now), now)
+	}
+	merged := merge(res.pending, ev)
+	res.pending = &merged
+	res.deadline = now.Add(d.Window)
+
+	state := ev.GetState()
+	if state == events.StateRemoved || state == events.StatePurged {
+		d.emit(*res.pending)
+		delete(d.resources, key)
+		return
+	}
+
+	if d.FlapThreshold > 0 && !res.flapping && len(res.changes) >= d.FlapThreshold {
+		res.flapping = true
+		flap := *res.pending
+		flap.Transition.Flapping = true
+		res.pending = nil
+		log.WithFields(log.Fields{
+			"kind":        ev.Kind,
+			"resourceId":  ev.GetResourceID(),
+			"transitions": len(res.changes),
+		}).Info("Resource is flapping")
+		d.emit(flap)
+		return
+	}
+
+	if d.Window == 0 && !res.flapping {
+		d.emit(*res.pending)
+		res.pending = nil
+	}
+}
+
+// flush passes on the events that settled and ends flapping
+// for resources with fewer recent transitions than the threshold.
+func (d *Debouncer) flush(now time.Time) {
+	for key, res := range d.resources {
+		res.changes = d.recent(res.changes, now)
+		if res.flapping && len(res.changes) < d.FlapThreshold {
+			res.flapping = false
+		}
+		if res.pending != nil && !res.flapping && !now.Before(res.deadline) {
+			d.emit(*res.pending)
+			res.pending = nil
+		}
+		if res.pending == nil && !res.flapping && len(res.changes) == 0 {
+			delete(d.resources, key)
+		}
+	}
+}
+
+// recent returns the transition times within the flap window.
+func (d *Debouncer) recent(changes []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(changes) && now.Sub(changes[i]) > d.FlapWindow {
+		i++
+	}
+	return changes[i:]
+}
+
+func (d *Debouncer) emit(ev events.Event) {
+	select {
+	case d.output <- ev:
+	case <-d.quitChan:
+	}
+}
+
+// merge returns the event with the transition computed relative to
+// the state before the first of the merged events.
+func merge(pending *events.Event, ev events.Event) events.Event {
+	if pending == nil {
+		return ev
+	}
+	merged := ev
+	merged.PreviousState = pending.PreviousState
+	merged.PreviousHealthState = pending.PreviousHealthState
+	merged.Transition = events.Transition{Initial: pending.Transition.Initial}
+	if !merged.Transition.Initial {
+		merged.Transition.StateChanged = merged.PreviousState != ev.GetState()
+		merged.Transition.HealthChanged = merged.PreviousHealthState != ev.GetHealthState()
+	}
+	return merged
+}
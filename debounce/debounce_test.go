@@ -0,0 +1,162 @@
+package debounce
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+var t0 = time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func newDebouncer(window time.Duration, flapThreshold int, flapWindow time.Duration) (*Debouncer, chan events.Event) {
+	output := make(chan events.Event, 10)
+	return New(window, flapThreshold, flapWindow, nil, output), output
+}
+
+// containerEvent returns an event of a container moving from prev to state.
+func containerEvent(id string, prev, state events.InstanceState) events.Event {
+	return events.Event{
+		ID:            id,
+		Kind:          events.ContainerEvent,
+		PreviousState: prev,
+		Transition:    events.Transition{StateChanged: prev != state},
+		ContainerData: events.Container{ID: "1i1", State: state},
+	}
+}
+
+// received returns the events passed on so far.
+func received(output chan events.Event) []events.Event {
+	var evs []events.Event
+	for {
+		select {
+		case ev := <-output:
+			evs = append(evs, ev)
+		default:
+			return evs
+		}
+	}
+}
+
+func TestMergeComputesTransitionFromFirstEvent(t *testing.T) {
+	d, output := newDebouncer(time.Second, 0, 0)
+
+	d.add(containerEvent("1", "running", "stopping"), t0)
+	d.add(containerEvent("2", "stopping", "stopped"), t0.Add(100*time.Millisecond))
+	d.add(containerEvent("3", "stopped", "starting"), t0.Add(200*time.Millisecond))
+	d.flush(t0.Add(time.Second))
+	if evs := received(output); len(evs) != 0 {
+		t.Fatalf("Got %d events before the window expired", len(evs))
+	}
+
+	d.flush(t0.Add(1200 * time.Millisecond))
+	evs := received(output)
+	if len(evs) != 1 {
+		t.Fatalf("Got %d events, want 1", len(evs))
+	}
+	ev := evs[0]
+	if ev.ID != "3" || ev.PreviousState != "running" || ev.GetState() != "starting" {
+		t.Errorf("Got event %s from %s to %s, want 3 from running to starting", ev.ID, ev.PreviousState, ev.GetState())
+	}
+	if !ev.Transition.StateChanged {
+		t.Error("Merged event is not marked as a state change")
+	}
+	if len(d.resources) != 0 {
+		t.Errorf("%d resources are still tracked", len(d.resources))
+	}
+}
+
+func TestMergeDetectsUnchangedState(t *testing.T) {
+	d, output := newDebouncer(time.Second, 0, 0)
+
+	d.add(containerEvent("1", "running", "stopped"), t0)
+	d.add(containerEvent("2", "stopped", "running"), t0.Add(100*time.Millisecond))
+	d.flush(t0.Add(2 * time.Second))
+
+	evs := received(output)
+	if len(evs) != 1 {
+		t.Fatalf("Got %d events, want 1", len(evs))
+	}
+	if evs[0].PreviousState != "running" || evs[0].Transition.StateChanged {
+		t.Errorf("Got transition %+v from %s, want no state change from running", evs[0].Transition, evs[0].PreviousState)
+	}
+}
+
+func TestRemovedIsPassedOnImmediately(t *testing.T) {
+	for _, state := range []events.InstanceState{events.StateRemoved, events.StatePurged} {
+		d, output := newDebouncer(time.Minute, 0, 0)
+
+		d.add(containerEvent("1", "running", "stopped"), t0)
+		d.add(containerEvent("2", "stopped", state), t0.Add(time.Second))
+
+		evs := received(output)
+		if len(evs) != 1 {
+			t.Fatalf("%s: got %d events, want 1", state, len(evs))
+		}
+		if evs[0].GetState() != state || evs[0].PreviousState != "running" {
+			t.Errorf("%s: got event from %s to %s", state, evs[0].PreviousState, evs[0].GetState())
+		}
+		if len(d.resources) != 0 {
+			t.Errorf("%s: %d resources are still tracked", state, len(d.resources))
+		}
+	}
+}
+
+func TestFlapping(t *testing.T) {
+	d, output := newDebouncer(time.Second, 3, 10*time.Second)
+
+	d.add(containerEvent("1", "running", "stopped"), t0)
+	d.add(containerEvent("2", "stopped", "running"), t0.Add(time.Second))
+	if evs := received(output); len(evs) != 0 {
+		t.Fatalf("Got %d events below the flap threshold", len(evs))
+	}
+
+	d.add(containerEvent("3", "running", "stopped"), t0.Add(2*time.Second))
+	evs := received(output)
+	if len(evs) != 1 || !evs[0].Transition.Flapping {
+		t.Fatalf("Got %+v, want a single flapping event", evs)
+	}
+
+	// further transitions are held back while the resource is flapping
+	d.add(containerEvent("4", "stopped", "running"), t0.Add(3*time.Second))
+	d.flush(t0.Add(5 * time.Second))
+	if evs := received(output); len(evs) != 0 {
+		t.Fatalf("Got %d events while flapping", len(evs))
+	}
+
+	// only the transition at 3s is left within the flap window
+	d.flush(t0.Add(12500 * time.Millisecond))
+	evs = received(output)
+	if len(evs) != 1 {
+		t.Fatalf("Got %d events after flapping ended, want 1", len(evs))
+	}
+	if ev := evs[0]; ev.ID != "4" || ev.GetState() != "running" || ev.Transition.Flapping {
+		t.Errorf("Got event %s in state %s with %+v, want the settled state", ev.ID, ev.GetState(), ev.Transition)
+	}
+
+	d.flush(t0.Add(20 * time.Second))
+	if evs := received(output); len(evs) != 0 {
+		t.Errorf("Got %d more events", len(evs))
+	}
+	if len(d.resources) != 0 {
+		t.Errorf("%d resources are still tracked", len(d.resources))
+	}
+}
+
+func TestZeroWindowPassesEventsThrough(t *testing.T) {
+	d, output := newDebouncer(0, 0, 0)
+
+	in := []events.Event{
+		containerEvent("1", "running", "stopped"),
+		containerEvent("2", "stopped", "starting"),
+		containerEvent("3", "starting", "running"),
+	}
+	for i, ev := range in {
+		d.add(ev, t0.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	if evs := received(output); !reflect.DeepEqual(evs, in) {
+		t.Errorf("Got %+v, want %+v", evs, in)
+	}
+}
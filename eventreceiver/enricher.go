@@ -0,0 +1,280 @@
+package eventreceiver
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+)
+
+// Enricher resolves related resources of events from the Rancher API.
+// Lookups are cached for the TTL, failed lookups for at most
+// negativeCacheTTL. Enriching an event waits at most for the timeout,
+// lookups still running afterwards complete in the background.
+type Enricher struct {
+	ttl      time.Duration
+	timeout  time.Duration
+	mu       sync.Mutex // guards cache and fetching
+	cache    map[string]cacheEntry
+	fetching map[string]*fetch
+}
+
+// Failed lookups are cached for this long, so that the event stream
+// is not slowed down by a resource that cannot be resolved.
+const negativeCacheTTL = 30 * time.Second
+
+var errResourceNotFound = errors.New("Resource not found")
+
+type cacheEntry struct {
+	value   interface{}
+	ok      bool
+	expires time.Time
+}
+
+// fetch is a lookup in progress. done is closed once entry is set.
+type fetch struct {
+	done  chan struct{}
+	entry cacheEntry
+}
+
+func NewEnricher(ttl, timeout time.Duration) *Enricher {
+	return &Enricher{
+		ttl:      ttl,
+		timeout:  timeout,
+		cache:    make(map[string]cacheEntry),
+		fetching: make(map[string]*fetch),
+	}
+}
+
+type hostInfo struct {
+	name string
+	ip   string
+}
+
+type serviceInfo struct {
+	name    string
+	stackID string
+}
+
+// Enrich sets the event's enrichment. Resources that cannot be
+// resolved are left out.
+func (e *Enricher) Enrich(ev *events.Event, cli *client.RancherClient) {
+	if cli == nil {
+		return
+	}
+	var en events.Enrichment
+	var accountID string
+	deadline := time.Now().Add(e.timeout)
+
+	switch ev.Kind {
+	case events.ContainerEvent:
+		data := ev.ContainerData
+		accountID = data.AccountID
+		if host, ok := e.host(cli, data.HostID, deadline); ok {
+			en.HostName = host.name
+			en.HostIP = host.ip
+		}
+		if serviceID, ok := e.containerService(cli, data.ID, deadline); ok {
+			if service, ok := e.service(cli, serviceID, deadline); ok {
+				en.ServiceName = service.name
+				en.StackName, _ = e.stack(cli, service.stackID, deadline)
+			}
+		}
+	case events.ServiceEvent:
+		accountID = ev.ServiceData.AccountID
+		en.StackName, _ = e.stack(cli, ev.ServiceData.EnvironmentID, deadline)
+	case events.HostEvent:
+		accountID = ev.HostData.AccountID
+	case events.StackEvent:
+		accountID = ev.StackData.AccountID
+	case events.LoadBalancerEvent:
+		accountID = ev.LoadBalancerData.AccountID
+		en.StackName, _ = e.stack(cli, ev.LoadBalancerData.EnvironmentID, deadline)
+	case events.VolumeEvent:
+		accountID = ev.VolumeData.AccountID
+	case events.CertificateEvent:
+		accountID = ev.CertificateData.AccountID
+	case events.RegistryEvent:
+		accountID = ev.RegistryData.AccountID
+	case events.StoragePoolEvent:
+		accountID = ev.StoragePoolData.AccountID
+	case events.AgentEvent:
+		accountID = ev.AgentData.AccountID
+	case events.ProjectEvent:
+		en.ProjectName = ev.ProjectData.Name
+	}
+	if accountID != "" {
+		en.ProjectName, _ = e.project(cli, accountID, deadline)
+	}
+
+	ev.Enrichment = en
+}
+
+// Len returns the number of cached lookups.
+func (e *Enricher) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.cache)
+}
+
+// lookup returns the cached result for the key or calls fetch and caches
+// its result. It waits for fetch at most until the deadline. Concurrent
+// lookups of the same key share a single fetch.
+func (e *Enricher) lookup(key string, deadline time.Time, fn func() (interface{}, error)) (interface{}, bool) {
+	e.mu.Lock()
+	if entry, ok := e.cache[key]; ok && time.Now().Before(entry.expires) {
+		e.mu.Unlock()
+		return entry.value, entry.ok
+	}
+	f, ok := e.fetching[key]
+	if !ok {
+		f = &fetch{done: make(chan struct{})}
+		e.fetching[key] = f
+		go e.fetch(key, f, fn)
+	}
+	e.mu.Unlock()
+
+	timer := time.NewTimer(deadline.Sub(time.Now()))
+	defer timer.Stop()
+	select {
+	case <-f.done:
+		return f.entry.value, f.entry.ok
+	case <-timer.C:
+		log.WithField("key", key).Debug("Timed out resolving resource for enrichment")
+		return nil, false
+	}
+}
+
+func (e *Enricher) fetch(key string, f *fetch, fn func() (interface{}, error)) {
+	value, err := fn()
+	now := time.Now()
+	f.entry = cacheEntry{value: value, ok: err == nil, expires: now.Add(e.ttl)}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"key":   key,
+			"error": err,
+		}).Debug("Could not resolve resource for enrichment")
+		if e.ttl > negativeCacheTTL {
+			f.entry.expires = now.Add(negativeCacheTTL)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for k, entry := range e.cache {
+		if now.After(entry.expires) {
+			delete(e.cache, k)
+		}
+	}
+	e.cache[key] = f.entry
+	delete(e.fetching, key)
+	close(f.done)
+}
+
+func (e *Enricher) host(cli *client.RancherClient, id string, deadline time.Time) (hostInfo, bool) {
+	if id == "" {
+		return hostInfo{}, false
+	}
+	v, ok := e.lookup("host/"+id, deadline, func() (interface{}, error) {
+		host, err := cli.Host.ById(id)
+		if err != nil || host == nil {
+			return nil, notFound(err)
+		}
+		name := host.Name
+		if name == "" {
+			name = host.Hostname
+		}
+		return hostInfo{name: name, ip: host.AgentIpAddress}, nil
+	})
+	if !ok {
+		return hostInfo{}, false
+	}
+	return v.(hostInfo), true
+}
+
+func (e *Enricher) service(cli *client.RancherClient, id string, deadline time.Time) (serviceInfo, bool) {
+	if id == "" {
+		return serviceInfo{}, false
+	}
+	v, ok := e.lookup("service/"+id, deadline, func() (interface{}, error) {
+		service, err := cli.Service.ById(id)
+		if err != nil || service == nil {
+			return nil, notFound(err)
+		}
+		return serviceInfo{name: service.Name, stackID: service.EnvironmentId}, nil
+	})
+	if !ok {
+		return serviceInfo{}, false
+	}
+	return v.(serviceInfo), true
+}
+
+// containerService returns the ID of the service the container belongs to.
+func (e *Enricher) containerService(cli *client.RancherClient, id string, deadline time.Time) (string, bool) {
+	if id == "" {
+		return "", false
+	}
+	v, ok := e.lookup("container-service/"+id, deadline, func() (interface{}, error) {
+		opts := client.NewListOpts()
+		opts.Filters["instanceId"] = id
+		maps, err := cli.ServiceExposeMap.List(opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(maps.Data) == 0 {
+			// standalone container
+			return "", nil
+		}
+		return maps.Data[0].ServiceId, nil
+	})
+	if !ok {
+		return "", false
+	}
+	serviceID := v.(string)
+	return serviceID, serviceID != ""
+}
+
+func (e *Enricher) stack(cli *client.RancherClient, id string, deadline time.Time) (string, bool) {
+	if id == "" {
+		return "", false
+	}
+	v, ok := e.lookup("stack/"+id, deadline, func() (interface{}, error) {
+		stack, err := cli.Environment.ById(id)
+		if err != nil || stack == nil {
+			return nil, notFound(err)
+		}
+		return stack.Name, nil
+	})
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+func (e *Enricher) project(cli *client.RancherClient, id string, deadline time.Time) (string, bool) {
+	if id == "" {
+		return "", false
+	}
+	v, ok := e.lookup("project/"+id, deadline, func() (interface{}, error) {
+		project, err := cli.Project.ById(id)
+		if err != nil || project == nil {
+			return nil, notFound(err)
+		}
+		return project.Name, nil
+	})
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+func notFound(err error) error {
+	if err != nil {
+		return err
+	}
+	return errResourceNotFound
+}
@@ -0,0 +1,81 @@
+package eventreceiver
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rancher/go-rancher/client"
+)
+
+// fakeHosts resolves hosts after the delay or fails if err is set.
+type fakeHosts struct {
+	client.HostOperations
+	delay time.Duration
+	err   error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeHosts) ById(id string) (*client.Host, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &client.Host{Name: "prod-03", AgentIpAddress: "10.0.0.3"}, nil
+}
+
+func (f *fakeHosts) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func TestEnricherCachesFailedLookups(t *testing.T) {
+	hosts := &fakeHosts{err: errors.New("forbidden")}
+	cli := &client.RancherClient{Host: hosts}
+	e := NewEnricher(time.Minute, time.Second)
+
+	for i := 0; i < 3; i++ {
+		if _, ok := e.host(cli, "1h1", time.Now().Add(time.Second)); ok {
+			t.Fatal("Failed lookup resolved the host")
+		}
+	}
+	if calls := hosts.callCount(); calls != 1 {
+		t.Errorf("%d API calls, want 1", calls)
+	}
+	if entry := e.cache["host/1h1"]; entry.expires.Sub(time.Now()) > negativeCacheTTL {
+		t.Errorf("Failed lookup cached for %s, want at most %s", entry.expires.Sub(time.Now()), negativeCacheTTL)
+	}
+}
+
+func TestEnricherTimesOut(t *testing.T) {
+	hosts := &fakeHosts{delay: 200 * time.Millisecond}
+	cli := &client.RancherClient{Host: hosts}
+	e := NewEnricher(time.Minute, 20*time.Millisecond)
+
+	start := time.Now()
+	if _, ok := e.host(cli, "1h1", start.Add(20*time.Millisecond)); ok {
+		t.Fatal("Slow lookup resolved the host before the timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Lookup returned after %s, want the timeout", elapsed)
+	}
+	// a concurrent lookup shares the running fetch
+	e.host(cli, "1h1", time.Now().Add(20*time.Millisecond))
+
+	// the lookup completes in the background and is cached
+	time.Sleep(300 * time.Millisecond)
+	host, ok := e.host(cli, "1h1", time.Now())
+	if !ok || host.name != "prod-03" {
+		t.Errorf("host = %+v, %v, want the cached host", host, ok)
+	}
+	if calls := hosts.callCount(); calls != 1 {
+		t.Errorf("%d API calls, want 1", calls)
+	}
+}
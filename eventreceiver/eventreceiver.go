@@ -1,10 +1,12 @@
 package eventreceiver
 
 import (
-	"fmt"
+	"sync"
+	"time"
 
 	"github.com/janeczku/eventbridge/config"
 	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/metrics"
 
 	log "github.com/Sirupsen/logrus"
 	revents "github.com/rancher/go-machine-service/events"
@@ -12,63 +14,136 @@ import (
 )
 
 var eventKindMapping = map[string]events.EventKind{
-	"container":   events.ContainerEvent,
-	"environment": events.StackEvent,
-	"host":        events.HostEvent,
-	"service":     events.ServiceEvent,
+	"container":           events.ContainerEvent,
+	"environment":         events.StackEvent,
+	"host":                events.HostEvent,
+	"service":             events.ServiceEvent,
+	"volume":              events.VolumeEvent,
+	"loadBalancerService": events.LoadBalancerEvent,
+	"certificate":         events.CertificateEvent,
+	"registry":            events.RegistryEvent,
+	"storagePool":         events.StoragePoolEvent,
+	"agent":               events.AgentEvent,
+	"project":             events.ProjectEvent,
+}
+
+// ReceiverMetrics tracks various metrics for the event receiver.
+type ReceiverMetrics struct {
+	sync.Mutex
+	Received   int                      // total events received from the event stream
+	Events     map[events.EventKind]int // total events passed on by kind
+	Reconnects int                      // total reconnects to the event stream
+	Connected  bool                     // whether the event stream is connected
+	Since      time.Time                // time the connection state last changed
+	LastEvent  time.Time                // time the last resource change event was received
+	LastPing   time.Time                // time the last ping was received
+	Latency    *metrics.Histogram       // delay between Rancher generating and receiving events
 }
 
 type EventReceiver struct {
 	output      chan events.Event
 	config      *config.AgentConfig
+	kindsMu     sync.RWMutex // guards eventKinds
 	eventKinds  map[events.EventKind]bool
+	stateCache  *StateCache
+	enricher    *Enricher
+	recorder    *Recorder
+	metrics     *ReceiverMetrics
+	quitChan    chan struct{}
+	waitGroup   *sync.WaitGroup
+	mu          sync.Mutex // guards eventRouter
 	eventRouter *revents.EventRouter
+	routerExit  chan error
 }
 
 func New(config *config.AgentConfig, eventKinds map[events.EventKind]bool, output chan events.Event) *EventReceiver {
+	var enricher *Enricher
+	if config.EnrichEvents {
+		enricher = NewEnricher(config.EnrichCacheTTLDuration, config.EnrichTimeoutDuration)
+	}
 	return &EventReceiver{
 		output:     output,
 		config:     config,
 		eventKinds: eventKinds,
+		stateCache: NewStateCache(),
+		enricher:   enricher,
+		metrics: &ReceiverMetrics{
+			Events:  make(map[events.EventKind]int),
+			Latency: metrics.NewHistogram(metrics.DefaultBuckets),
+		},
+		quitChan:  make(chan struct{}),
+		waitGroup: &sync.WaitGroup{},
 	}
 }
 
+// Start connects to the event stream and supervises the connection.
 func (r *EventReceiver) Start() error {
 	log.WithField("rancherURL", r.config.RancherURL).Debug("Starting event receiver")
-	eventHandlers := map[string]revents.EventHandler{
-		"resource.change": r.EventHandler,
-		"ping":            r.PingNoOp,
+	if r.config.RecordFile != "" {
+		recorder, err := NewRecorder(r.config.RecordFile)
+		if err != nil {
+			return err
+		}
+		r.recorder = recorder
+		log.WithField("file", r.config.RecordFile).Info("Recording events")
 	}
-
-	router, err := revents.NewEventRouter("", 0, r.config.RancherURL, r.config.RancherAccessKey,
-		r.config.RancherSecretKey, nil, eventHandlers, "", r.config.EventReceiverCount)
-	if err != nil {
-		return fmt.Errorf("Could not connect to event stream: %v", err)
+	if err := r.connect(); err != nil {
+		return err
 	}
 
-	r.eventRouter = router
+	r.waitGroup.Add(1)
+	go r.supervise()
 
-	readyChan := make(chan bool)
-	errorChan := make(chan error)
+	return nil
+}
 
-	go func(c chan error) {
-		err = r.eventRouter.StartWithoutCreate(readyChan)
-		c <- err
-	}(errorChan)
+// Stop stops supervising and closes the event stream connection.
+func (r *EventReceiver) Stop() error {
+	log.Debug("Stopping event receiver")
+	close(r.quitChan)
 
-	select {
-	case <-readyChan:
-		break
-	case err := <-errorChan:
-		return fmt.Errorf("Event stream listener exited: %v", err)
+	var err error
+	r.mu.Lock()
+	if r.eventRouter != nil {
+		err = r.eventRouter.Stop()
 	}
+	r.mu.Unlock()
 
-	return nil
+	r.waitGroup.Wait()
+
+	if r.recorder != nil {
+		if cerr := r.recorder.Close(); cerr != nil {
+			log.WithField("error", cerr).Error("Error closing recording file")
+		}
+	}
+	return err
 }
 
-func (r *EventReceiver) Stop() error {
-	log.Debug("Stopping event receiver")
-	return r.eventRouter.Stop()
+// SetEventKinds replaces the kinds of events passed on by the receiver.
+func (r *EventReceiver) SetEventKinds(eventKinds map[events.EventKind]bool) {
+	r.kindsMu.Lock()
+	r.eventKinds = eventKinds
+	r.kindsMu.Unlock()
+}
+
+// Stats returns a copy of the receiver metrics.
+func (r *EventReceiver) Stats() *ReceiverMetrics {
+	r.metrics.Lock()
+	defer r.metrics.Unlock()
+	stats := &ReceiverMetrics{
+		Received:   r.metrics.Received,
+		Events:     make(map[events.EventKind]int, len(r.metrics.Events)),
+		Reconnects: r.metrics.Reconnects,
+		Connected:  r.metrics.Connected,
+		Since:      r.metrics.Since,
+		LastEvent:  r.metrics.LastEvent,
+		LastPing:   r.metrics.LastPing,
+		Latency:    r.metrics.Latency,
+	}
+	for kind, count := range r.metrics.Events {
+		stats.Events[kind] = count
+	}
+	return stats
 }
 
 func (r *EventReceiver) EventHandler(ev *revents.Event, cli *client.RancherClient) error {
@@ -79,25 +154,57 @@ func (r *EventReceiver) EventHandler(ev *revents.Event, cli *client.RancherClien
 		"EventKind":  ev.ResourceType,
 	}).Debug("Received event")
 
+	if r.recorder != nil {
+		r.recorder.Record(ev)
+	}
+
+	r.metrics.Lock()
+	r.metrics.Received++
+	r.metrics.LastEvent = time.Now()
+	r.metrics.Unlock()
+
 	var kind events.EventKind
 	if val, ok := eventKindMapping[ev.ResourceType]; ok {
 		kind = val
 	}
 
+	r.kindsMu.RLock()
 	_, wanted := r.eventKinds[kind]
+	r.kindsMu.RUnlock()
 	if len(kind) == 0 || !wanted {
 		return nil
 	}
 
-	return r.transformEvent(ev, kind)
+	newEvent, err := r.transformEvent(ev, kind)
+	if err != nil {
+		return nil
+	}
+
+	if ev.Time > 0 {
+		r.metrics.Latency.Observe(newEvent.Latency().Seconds())
+	}
+
+	r.enrich(&newEvent, cli)
+	r.emit(newEvent)
+	return nil
 }
 
 func (r *EventReceiver) PingNoOp(ev *revents.Event, cli *client.RancherClient) error {
+	r.metrics.Lock()
+	r.metrics.LastPing = time.Now()
+	r.metrics.Unlock()
 	return nil
 }
 
-func (r *EventReceiver) transformEvent(ev *revents.Event, kind events.EventKind) error {
-	resourceData := ev.Data["resource"].(map[string]interface{})
+func (r *EventReceiver) setConnected(connected bool) {
+	r.metrics.Lock()
+	r.metrics.Connected = connected
+	r.metrics.Since = time.Now()
+	r.metrics.Unlock()
+}
+
+func (r *EventReceiver) transformEvent(ev *revents.Event, kind events.EventKind) (events.Event, error) {
+	resourceData, _ := ev.Data["resource"].(map[string]interface{})
 	newEvent, err := events.New(ev.ID, kind, resourceData)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -106,15 +213,56 @@ func (r *EventReceiver) transformEvent(ev *revents.Event, kind events.EventKind)
 			"resourceData": resourceData,
 			"error":        err,
 		}).Error("Failed to transform event")
-		return nil
+		return newEvent, err
+	}
+
+	newEvent.Envelope = events.Envelope{
+		Name:          ev.Name,
+		ID:            ev.ID,
+		ResourceID:    ev.ResourceID,
+		ResourceType:  ev.ResourceType,
+		Publisher:     ev.Publisher,
+		ReplyTo:       ev.ReplyTo,
+		PreviousNames: ev.PreviousNames,
+		Time:          ev.Time,
 	}
+	if ev.Time > 0 {
+		newEvent.Timestamp = time.Unix(0, ev.Time*int64(time.Millisecond)).UTC()
+	}
+	if newEvent.Transitioning.IsZero() && ev.Transitioning != events.TransitioningNo {
+		newEvent.Transitioning = events.Transitioning{
+			State:    ev.Transitioning,
+			Message:  ev.TransitioningMessage,
+			Progress: ev.TransitioningProgress,
+		}
+	}
+
+	r.stateCache.Annotate(&newEvent)
 
 	log.WithFields(log.Fields{
-		"eventID": newEvent.ID,
-		"kind":    newEvent.Kind,
+		"eventID":    newEvent.ID,
+		"kind":       newEvent.Kind,
+		"transition": newEvent.Transition,
 	}).Debug("Transformed event")
 
-	r.output <- newEvent
+	return newEvent, nil
+}
 
-	return nil
+// enrich resolves related resources of the event if enrichment is enabled.
+func (r *EventReceiver) enrich(ev *events.Event, cli *client.RancherClient) {
+	if r.enricher != nil {
+		r.enricher.Enrich(ev, cli)
+	}
+}
+
+// emit passes the event on to the output channel.
+func (r *EventReceiver) emit(ev events.Event) {
+	r.metrics.Lock()
+	r.metrics.Events[ev.Kind]++
+	r.metrics.Unlock()
+
+	select {
+	case r.output <- ev:
+	case <-r.quitChan:
+	}
 }
@@ -0,0 +1,61 @@
+package eventreceiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	revents "github.com/rancher/go-machine-service/events"
+)
+
+// Record is a line of a recording file.
+type Record struct {
+	RecordedAt time.Time      `json:"recordedAt"`
+	Event      *revents.Event `json:"event"`
+}
+
+// Recorder appends the events received from the event stream to a
+// JSON lines file, so they can be replayed later.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewRecorder opens the recording file, creating it if necessary.
+func NewRecorder(path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("Could not create recording directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open recording file: %v", err)
+	}
+	return &Recorder{
+		file: file,
+		enc:  json.NewEncoder(file),
+	}, nil
+}
+
+// Record writes the event to the recording file.
+func (rec *Recorder) Record(ev *revents.Event) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := rec.enc.Encode(Record{RecordedAt: time.Now().UTC(), Event: ev}); err != nil {
+		log.WithFields(log.Fields{
+			"eventId": ev.ID,
+			"error":   err,
+		}).Error("Failed to record event")
+	}
+}
+
+// Close closes the recording file.
+func (rec *Recorder) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.file.Close()
+}
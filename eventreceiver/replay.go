@@ -0,0 +1,62 @@
+package eventreceiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Replay passes the events of a recording file through the event handler
+// as if they were received from the event stream and returns the number of
+// replayed events. The delays between the events are divided by speed:
+// 1 replays in real time, 10 ten times faster and 0 as fast as possible.
+// Events are not enriched as no Rancher API client is available.
+func (r *EventReceiver) Replay(path string, speed float64) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("Could not open recording file: %v", err)
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	var count int
+	var last time.Time
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("Invalid record %d in %s: %v", count+1, path, err)
+		}
+		if rec.Event == nil {
+			continue
+		}
+
+		if speed > 0 && !last.IsZero() && rec.RecordedAt.After(last) {
+			delay := time.Duration(float64(rec.RecordedAt.Sub(last)) / speed)
+			select {
+			case <-time.After(delay):
+			case <-r.quitChan:
+				return count, nil
+			}
+		}
+		last = rec.RecordedAt
+
+		select {
+		case <-r.quitChan:
+			return count, nil
+		default:
+		}
+
+		log.WithFields(log.Fields{
+			"eventId":    rec.Event.ID,
+			"recordedAt": rec.RecordedAt,
+		}).Debug("Replaying event")
+		r.EventHandler(rec.Event, nil)
+		count++
+	}
+}
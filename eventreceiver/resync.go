@@ -0,0 +1,153 @@
+package eventreceiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	revents "github.com/rancher/go-machine-service/events"
+	"github.com/rancher/go-rancher/client"
+)
+
+// resync fetches the current state of all resources of the wanted kinds
+// from the Rancher API and emits events for resources whose state changed
+// while the event stream was disconnected.
+func (r *EventReceiver) resync() {
+	log.Info("Resyncing resource states")
+	cli, err := client.NewRancherClient(&client.ClientOpts{
+		Url:       r.config.RancherURL,
+		AccessKey: r.config.RancherAccessKey,
+		SecretKey: r.config.RancherSecretKey,
+	})
+	if err != nil {
+		log.WithField("error", err).Error("Could not create Rancher API client for resync")
+		return
+	}
+
+	resources := make(map[string][]map[string]interface{})
+	var listErr error
+	for _, l := range resourceLists(cli) {
+		if !r.wantsAny(l.types) {
+			continue
+		}
+		coll, err := l.list()
+		if err == nil {
+			err = r.addResources(resources, l.types[0], coll)
+		}
+		if err != nil {
+			listErr = err
+		}
+	}
+	if listErr != nil {
+		log.WithField("error", listErr).Error("Could not list resources for resync")
+	}
+
+	emitted := 0
+	for resourceType, list := range resources {
+		for _, resource := range list {
+			if r.resyncResource(resourceType, resource, cli) {
+				emitted++
+			}
+		}
+	}
+
+	log.WithField("changed", emitted).Info("Resync finished")
+}
+
+// resourceList lists the resources of one or more Rancher resource types.
+type resourceList struct {
+	types []string // resource types included in the list
+	list  func() (interface{}, error)
+}
+
+// resourceLists returns the lists fetched by a resync. The service list
+// also includes the load balancers, each resource is keyed by its own type.
+func resourceLists(cli *client.RancherClient) []resourceList {
+	opts := client.NewListOpts()
+	return []resourceList{
+		{[]string{"container"}, func() (interface{}, error) { return cli.Container.List(opts) }},
+		{[]string{"service", "loadBalancerService"}, func() (interface{}, error) { return cli.Service.List(opts) }},
+		{[]string{"environment"}, func() (interface{}, error) { return cli.Environment.List(opts) }},
+		{[]string{"host"}, func() (interface{}, error) { return cli.Host.List(opts) }},
+		{[]string{"volume"}, func() (interface{}, error) { return cli.Volume.List(opts) }},
+		{[]string{"certificate"}, func() (interface{}, error) { return cli.Certificate.List(opts) }},
+		{[]string{"registry"}, func() (interface{}, error) { return cli.Registry.List(opts) }},
+		{[]string{"storagePool"}, func() (interface{}, error) { return cli.StoragePool.List(opts) }},
+		{[]string{"agent"}, func() (interface{}, error) { return cli.Agent.List(opts) }},
+		{[]string{"project"}, func() (interface{}, error) { return cli.Project.List(opts) }},
+	}
+}
+
+// addResources adds the wanted resources of an API collection keyed by
+// their type. Resources without a type are keyed by defaultType.
+func (r *EventReceiver) addResources(resources map[string][]map[string]interface{}, defaultType string, coll interface{}) error {
+	b, err := json.Marshal(coll)
+	if err != nil {
+		return err
+	}
+	var c struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
+	}
+	for _, resource := range c.Data {
+		resourceType, _ := resource["type"].(string)
+		if resourceType == "" {
+			resourceType = defaultType
+		}
+		if r.wants(resourceType) {
+			resources[resourceType] = append(resources[resourceType], resource)
+		}
+	}
+	return nil
+}
+
+// wantsAny returns true if events of any of the given Rancher resource types are wanted.
+func (r *EventReceiver) wantsAny(resourceTypes []string) bool {
+	for _, resourceType := range resourceTypes {
+		if r.wants(resourceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// wants returns true if events of the given Rancher resource type are wanted.
+func (r *EventReceiver) wants(resourceType string) bool {
+	kind, ok := eventKindMapping[resourceType]
+	if !ok {
+		return false
+	}
+	r.kindsMu.RLock()
+	defer r.kindsMu.RUnlock()
+	_, ok = r.eventKinds[kind]
+	return ok
+}
+
+// resyncResource passes a resource fetched from the API through the event
+// transformation and emits the event if the resource's state changed.
+func (r *EventReceiver) resyncResource(resourceType string, data map[string]interface{}, cli *client.RancherClient) bool {
+	id, _ := data["id"].(string)
+	ev := &revents.Event{
+		Name:         "resource.change",
+		ID:           fmt.Sprintf("resync-%s-%d", id, time.Now().UnixNano()),
+		ResourceID:   id,
+		ResourceType: resourceType,
+		Data:         map[string]interface{}{"resource": data},
+	}
+
+	newEvent, err := r.transformEvent(ev, eventKindMapping[resourceType])
+	if err != nil {
+		return false
+	}
+
+	if !newEvent.Transition.StateChanged && !newEvent.Transition.HealthChanged {
+		return false
+	}
+
+	r.enrich(&newEvent, cli)
+	r.emit(newEvent)
+	return true
+}
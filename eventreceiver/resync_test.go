@@ -0,0 +1,66 @@
+package eventreceiver
+
+import (
+	"testing"
+
+	"github.com/janeczku/eventbridge/config"
+	"github.com/janeczku/eventbridge/events"
+
+	"github.com/rancher/go-rancher/client"
+)
+
+func TestAddResourcesKeysResourcesByType(t *testing.T) {
+	services := &client.ServiceCollection{Data: []client.Service{
+		{Resource: client.Resource{Id: "1s1", Type: "service"}},
+		{Resource: client.Resource{Id: "1s2", Type: "loadBalancerService"}},
+		{Resource: client.Resource{Id: "1s3", Type: "externalService"}},
+		{Resource: client.Resource{Id: "1s4"}},
+	}}
+
+	tests := []struct {
+		kinds []events.EventKind
+		want  map[string][]string
+	}{
+		{
+			[]events.EventKind{events.ServiceEvent, events.LoadBalancerEvent},
+			map[string][]string{"service": {"1s1", "1s4"}, "loadBalancerService": {"1s2"}},
+		},
+		{
+			[]events.EventKind{events.ServiceEvent},
+			map[string][]string{"service": {"1s1", "1s4"}},
+		},
+		{
+			[]events.EventKind{events.LoadBalancerEvent},
+			map[string][]string{"loadBalancerService": {"1s2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		kinds := make(map[events.EventKind]bool)
+		for _, kind := range tt.kinds {
+			kinds[kind] = true
+		}
+		r := New(&config.AgentConfig{}, kinds, nil)
+
+		resources := make(map[string][]map[string]interface{})
+		if err := r.addResources(resources, "service", services); err != nil {
+			t.Fatalf("addResources: %v", err)
+		}
+		if len(resources) != len(tt.want) {
+			t.Errorf("%v: got resource types %v, want %v", tt.kinds, resources, tt.want)
+			continue
+		}
+		for resourceType, ids := range tt.want {
+			list := resources[resourceType]
+			if len(list) != len(ids) {
+				t.Errorf("%v: got %d resources of type %s, want %d", tt.kinds, len(list), resourceType, len(ids))
+				continue
+			}
+			for i, id := range ids {
+				if list[i]["id"] != id {
+					t.Errorf("%v: %s resource %d has id %v, want %s", tt.kinds, resourceType, i, list[i]["id"], id)
+				}
+			}
+		}
+	}
+}
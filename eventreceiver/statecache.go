@@ -0,0 +1,63 @@
+package eventreceiver
+
+import (
+	"sync"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+type resourceState struct {
+	state       events.InstanceState
+	healthState events.HealthState
+}
+
+// StateCache keeps track of the last known state of each resource
+// so that events can be annotated with the state transition.
+type StateCache struct {
+	sync.Mutex
+	states map[string]resourceState
+}
+
+func NewStateCache() *StateCache {
+	return &StateCache{
+		states: make(map[string]resourceState),
+	}
+}
+
+// Annotate sets the previous state and the transition of the event
+// and records the event's state as the current state of the resource.
+func (c *StateCache) Annotate(ev *events.Event) {
+	key := string(ev.Kind) + "/" + ev.GetResourceID()
+	current := resourceState{
+		state:       ev.GetState(),
+		healthState: ev.GetHealthState(),
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	previous, ok := c.states[key]
+	if !ok {
+		ev.Transition = events.Transition{Initial: true}
+	} else {
+		ev.PreviousState = previous.state
+		ev.PreviousHealthState = previous.healthState
+		ev.Transition = events.Transition{
+			StateChanged:  previous.state != current.state,
+			HealthChanged: previous.healthState != current.healthState,
+		}
+	}
+
+	if current.state == events.StateRemoved || current.state == events.StatePurged {
+		delete(c.states, key)
+		return
+	}
+	c.states[key] = current
+}
+
+// Len returns the number of tracked resources.
+func (c *StateCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.states)
+}
@@ -0,0 +1,143 @@
+package eventreceiver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/janeczku/eventbridge/backoff"
+
+	log "github.com/Sirupsen/logrus"
+	revents "github.com/rancher/go-machine-service/events"
+)
+
+const (
+	reconnectInitialBackoff = time.Second
+	idleCheckInterval       = 10 * time.Second
+)
+
+// connect creates a new event router and waits until it is listening.
+func (r *EventReceiver) connect() error {
+	eventHandlers := map[string]revents.EventHandler{
+		"resource.change": r.EventHandler,
+		"ping":            r.PingNoOp,
+	}
+
+	router, err := revents.NewEventRouter("", 0, r.config.RancherURL, r.config.RancherAccessKey,
+		r.config.RancherSecretKey, nil, eventHandlers, "", r.config.EventReceiverCount)
+	if err != nil {
+		return fmt.Errorf("Could not connect to event stream: %v", err)
+	}
+
+	readyChan := make(chan bool, 1)
+	exitChan := make(chan error, 1)
+
+	go func(c chan error) {
+		c <- router.StartWithoutCreate(readyChan)
+	}(exitChan)
+
+	select {
+	case <-readyChan:
+		break
+	case err := <-exitChan:
+		return fmt.Errorf("Event stream listener exited: %v", err)
+	}
+
+	r.mu.Lock()
+	r.eventRouter = router
+	r.mu.Unlock()
+	r.routerExit = exitChan
+	r.setConnected(true)
+
+	return nil
+}
+
+// supervise reconnects the event router when it exits or
+// when the event stream has been idle for too long.
+func (r *EventReceiver) supervise() {
+	defer r.waitGroup.Done()
+
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.quitChan:
+			return
+		case err := <-r.routerExit:
+			select {
+			case <-r.quitChan:
+				return
+			default:
+			}
+			r.setConnected(false)
+			log.WithField("error", err).Warn("Event stream disconnected")
+			r.reconnect()
+		case <-ticker.C:
+			r.checkIdle()
+		}
+	}
+}
+
+// checkIdle stops the event router if neither events nor pings were
+// received within the idle timeout. The supervisor then reconnects.
+func (r *EventReceiver) checkIdle() {
+	timeout := r.config.ReconnectIdleTimeoutDuration
+	if timeout <= 0 {
+		return
+	}
+
+	stats := r.Stats()
+	last := stats.Since
+	for _, t := range []time.Time{stats.LastEvent, stats.LastPing} {
+		if t.After(last) {
+			last = t
+		}
+	}
+	if time.Since(last) < timeout {
+		return
+	}
+
+	log.WithField("idle", time.Since(last).String()).Warn("Event stream idle, forcing reconnect")
+	r.mu.Lock()
+	err := r.eventRouter.Stop()
+	r.mu.Unlock()
+	if err != nil {
+		log.WithField("error", err).Warn("Error stopping idle event stream listener")
+	}
+}
+
+// reconnect connects a new event router, retrying with exponential
+// backoff until it succeeds or the receiver is stopped.
+func (r *EventReceiver) reconnect() {
+	for attempt := 1; ; attempt++ {
+		delay := backoff.Exponential(attempt, reconnectInitialBackoff, r.config.ReconnectMaxBackoffDuration)
+		log.WithFields(log.Fields{
+			"attempt": attempt,
+			"delay":   delay.String(),
+		}).Info("Reconnecting to event stream")
+
+		select {
+		case <-r.quitChan:
+			return
+		case <-time.After(delay):
+		}
+
+		r.metrics.Lock()
+		r.metrics.Reconnects++
+		r.metrics.Unlock()
+
+		if err := r.connect(); err != nil {
+			log.WithFields(log.Fields{
+				"attempt": attempt,
+				"error":   err,
+			}).Error("Failed to reconnect to event stream")
+			continue
+		}
+
+		log.WithField("attempt", attempt).Info("Reconnected to event stream")
+		if r.config.ResyncOnReconnect {
+			r.resync()
+		}
+		return
+	}
+}
@@ -5,8 +5,31 @@ const (
 	HostEvent      EventKind = "host"
 	ServiceEvent   EventKind = "service"
 	StackEvent     EventKind = "stack"
+
+	VolumeEvent       EventKind = "volume"
+	LoadBalancerEvent EventKind = "loadbalancer"
+	CertificateEvent  EventKind = "certificate"
+	RegistryEvent     EventKind = "registry"
+	StoragePoolEvent  EventKind = "storagepool"
+	AgentEvent        EventKind = "agent"
+	ProjectEvent      EventKind = "project"
 )
 
+// AllEventKinds lists all kinds of events.
+var AllEventKinds = []EventKind{
+	ContainerEvent,
+	HostEvent,
+	ServiceEvent,
+	StackEvent,
+	VolumeEvent,
+	LoadBalancerEvent,
+	CertificateEvent,
+	RegistryEvent,
+	StoragePoolEvent,
+	AgentEvent,
+	ProjectEvent,
+}
+
 const (
 	// Common health states
 	StateHealthy           HealthState = "healthy"
@@ -26,6 +49,11 @@ const (
 )
 
 const (
+	// Common states
+	StateRemoved InstanceState = "removed"
+	StatePurged  InstanceState = "purged"
+	StateError   InstanceState = "error"
+
 	// Service states
 	ServiceInactive         InstanceState = "inactive"
 	ServiceActivating       InstanceState = "activating"
@@ -45,4 +73,14 @@ const (
 	HostInactive   InstanceState = "inactive"
 	HostActivating InstanceState = "activating"
 	HostActive     InstanceState = "active"
+
+	// Volume states
+	VolumeActive   InstanceState = "active"
+	VolumeInactive InstanceState = "inactive"
+	VolumeDetached InstanceState = "detached"
+
+	// Agent states
+	AgentActive       InstanceState = "active"
+	AgentDisconnected InstanceState = "disconnected"
+	AgentReconnecting InstanceState = "reconnecting"
 )
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"fmt"
+)
+
+// Enrichment holds information about related resources that is not part
+// of the event payload and has been resolved from the Rancher API.
+type Enrichment struct {
+	HostName    string `json:"hostName,omitempty"`
+	HostIP      string `json:"hostIp,omitempty"`
+	ServiceName string `json:"serviceName,omitempty"`
+	StackName   string `json:"stackName,omitempty"`
+	ProjectName string `json:"projectName,omitempty"`
+}
+
+// IsZero returns true if nothing has been resolved.
+func (e Enrichment) IsZero() bool {
+	return e == Enrichment{}
+}
+
+// GetStackName returns the name of the stack the resource belongs to.
+func (ev Event) GetStackName() string {
+	switch ev.Kind {
+	case ContainerEvent:
+		if ev.ContainerData.StackName != "" {
+			return ev.ContainerData.StackName
+		}
+	case StackEvent:
+		return ev.StackData.Name
+	}
+	return ev.Enrichment.StackName
+}
+
+// GetServiceName returns the name of the service the resource belongs to.
+func (ev Event) GetServiceName() string {
+	switch ev.Kind {
+	case ContainerEvent:
+		if ev.ContainerData.ServiceName != "" {
+			return ev.ContainerData.ServiceName
+		}
+	case ServiceEvent:
+		return ev.ServiceData.Name
+	case LoadBalancerEvent:
+		return ev.LoadBalancerData.Name
+	}
+	return ev.Enrichment.ServiceName
+}
+
+// Describe returns the name of the resource along with its host and
+// stack, e.g. "web-1 on host prod-03 (stack shop)", as far as known.
+func (ev Event) Describe() string {
+	desc := ev.GetName()
+	if ev.Enrichment.HostName != "" {
+		desc += " on host " + ev.Enrichment.HostName
+	}
+	if stack := ev.GetStackName(); stack != "" && ev.Kind != StackEvent {
+		desc += fmt.Sprintf(" (stack %s)", stack)
+	}
+	return desc
+}
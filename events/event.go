@@ -10,20 +10,54 @@ import (
 
 // Event is used to store information relating to a Rancher API "resource.change" event
 type Event struct {
-	ID            string
-	Timestamp     time.Time
-	Kind          EventKind
-	ContainerData Container
-	HostData      Host
-	ServiceData   Service
-	StackData     Stack
+	ID                  string
+	Timestamp           time.Time // time Rancher generated the event, ReceivedAt if unknown
+	ReceivedAt          time.Time // time eventbridge received the event
+	Kind                EventKind
+	PreviousState       InstanceState
+	PreviousHealthState HealthState
+	Transition          Transition
+	Transitioning       Transitioning
+	Severity            Severity
+	Enrichment          Enrichment
+	Envelope            Envelope               // metadata of the Rancher event
+	Raw                 map[string]interface{} // resource as received, see RawField
+	ContainerData       Container
+	HostData            Host
+	ServiceData         Service
+	StackData           Stack
+	VolumeData          Volume
+	LoadBalancerData    LoadBalancer
+	CertificateData     Certificate
+	RegistryData        Registry
+	StoragePoolData     StoragePool
+	AgentData           Agent
+	ProjectData         Project
+}
+
+// Transition describes how the state of a resource changed compared
+// to the previous event received for the same resource.
+type Transition struct {
+	Initial       bool // no previous event was seen for the resource
+	StateChanged  bool
+	HealthChanged bool
+	Flapping      bool // the resource changes state too often, see package debounce
+}
+
+// Changed returns true if the resource is new, flapping or if its state or health state changed.
+func (t Transition) Changed() bool {
+	return t.Initial || t.StateChanged || t.HealthChanged || t.Flapping
 }
 
 func New(id string, kind EventKind, resourceData map[string]interface{}) (Event, error) {
+	now := time.Now().UTC()
 	ev := Event{
-		ID:        id,
-		Timestamp: time.Now().UTC(),
-		Kind:      kind,
+		ID:            id,
+		Timestamp:     now,
+		ReceivedAt:    now,
+		Kind:          kind,
+		Transitioning: parseTransitioning(resourceData),
+		Raw:           resourceData,
 	}
 
 	var err error
@@ -37,6 +71,20 @@ func New(id string, kind EventKind, resourceData map[string]interface{}) (Event,
 		err = mapstructure.WeakDecode(resourceData, &ev.ServiceData)
 	case StackEvent:
 		err = mapstructure.WeakDecode(resourceData, &ev.StackData)
+	case VolumeEvent:
+		err = mapstructure.WeakDecode(resourceData, &ev.VolumeData)
+	case LoadBalancerEvent:
+		err = mapstructure.WeakDecode(resourceData, &ev.LoadBalancerData)
+	case CertificateEvent:
+		err = mapstructure.WeakDecode(resourceData, &ev.CertificateData)
+	case RegistryEvent:
+		err = mapstructure.WeakDecode(resourceData, &ev.RegistryData)
+	case StoragePoolEvent:
+		err = mapstructure.WeakDecode(resourceData, &ev.StoragePoolData)
+	case AgentEvent:
+		err = mapstructure.WeakDecode(resourceData, &ev.AgentData)
+	case ProjectEvent:
+		err = mapstructure.WeakDecode(resourceData, &ev.ProjectData)
 	default:
 		return Event{}, fmt.Errorf("Unknown event kind: %s", kind)
 	}
@@ -59,6 +107,20 @@ func (ev Event) GetState() InstanceState {
 		state = ev.ServiceData.State
 	case StackEvent:
 		state = ev.StackData.State
+	case VolumeEvent:
+		state = ev.VolumeData.State
+	case LoadBalancerEvent:
+		state = ev.LoadBalancerData.State
+	case CertificateEvent:
+		state = ev.CertificateData.State
+	case RegistryEvent:
+		state = ev.RegistryData.State
+	case StoragePoolEvent:
+		state = ev.StoragePoolData.State
+	case AgentEvent:
+		state = ev.AgentData.State
+	case ProjectEvent:
+		state = ev.ProjectData.State
 	}
 	return state
 }
@@ -72,6 +134,8 @@ func (ev Event) GetHealthState() HealthState {
 		healthState = ev.ServiceData.HealthState
 	case StackEvent:
 		healthState = ev.StackData.HealthState
+	case LoadBalancerEvent:
+		healthState = ev.LoadBalancerData.HealthState
 	}
 
 	if len(healthState) == 0 {
@@ -81,6 +145,35 @@ func (ev Event) GetHealthState() HealthState {
 	return healthState
 }
 
+func (ev Event) GetResourceID() string {
+	var id string
+	switch ev.Kind {
+	case ContainerEvent:
+		id = ev.ContainerData.ID
+	case HostEvent:
+		id = ev.HostData.ID
+	case ServiceEvent:
+		id = ev.ServiceData.ID
+	case StackEvent:
+		id = ev.StackData.ID
+	case VolumeEvent:
+		id = ev.VolumeData.ID
+	case LoadBalancerEvent:
+		id = ev.LoadBalancerData.ID
+	case CertificateEvent:
+		id = ev.CertificateData.ID
+	case RegistryEvent:
+		id = ev.RegistryData.ID
+	case StoragePoolEvent:
+		id = ev.StoragePoolData.ID
+	case AgentEvent:
+		id = ev.AgentData.ID
+	case ProjectEvent:
+		id = ev.ProjectData.ID
+	}
+	return id
+}
+
 func (ev Event) GetName() string {
 	var name string
 	switch ev.Kind {
@@ -92,11 +185,65 @@ func (ev Event) GetName() string {
 		name = ev.ServiceData.Name
 	case StackEvent:
 		name = ev.StackData.Name
+	case VolumeEvent:
+		name = ev.VolumeData.Name
+	case LoadBalancerEvent:
+		name = ev.LoadBalancerData.Name
+	case CertificateEvent:
+		name = ev.CertificateData.Name
+	case RegistryEvent:
+		name = ev.RegistryData.Name
+	case StoragePoolEvent:
+		name = ev.StoragePoolData.Name
+	case AgentEvent:
+		name = ev.AgentData.Name
+	case ProjectEvent:
+		name = ev.ProjectData.Name
 	}
 	return name
 }
 
+// GetLabels returns the labels of the resource. The labels of services
+// and load balancers are taken from their launch config.
+func (ev Event) GetLabels() map[string]string {
+	switch ev.Kind {
+	case ContainerEvent:
+		return ev.ContainerData.Labels
+	case HostEvent:
+		return ev.HostData.Labels
+	case ServiceEvent:
+		if lc := ev.ServiceData.LaunchConfig; lc != nil {
+			return lc.Labels
+		}
+	case LoadBalancerEvent:
+		if len(ev.LoadBalancerData.Labels) > 0 {
+			return ev.LoadBalancerData.Labels
+		}
+		if lc := ev.LoadBalancerData.LaunchConfig; lc != nil {
+			return lc.Labels
+		}
+	}
+	return nil
+}
+
 func (ev Event) String() string {
+	s := ev.describeTransition()
+	if msg := ev.Transitioning.Message; msg != "" {
+		s += ": " + msg
+	}
+	return s
+}
+
+func (ev Event) describeTransition() string {
+	if ev.Transition.Flapping {
+		return fmt.Sprintf("[%s] %s '%s' is flapping, currently in the '%s' state (health: '%s')",
+			ev.Timestamp.Format("2006-01-02 15:04:05"), ev.Kind, ev.GetName(), ev.GetState(), ev.GetHealthState())
+	}
+	if ev.Transition.StateChanged && !ev.Transition.Initial {
+		return fmt.Sprintf("[%s] %s '%s' changed from the '%s' to the '%s' state (health: '%s')",
+			ev.Timestamp.Format("2006-01-02 15:04:05"), ev.Kind, ev.GetName(), ev.PreviousState,
+			ev.GetState(), ev.GetHealthState())
+	}
 	return fmt.Sprintf("[%s] %s '%s' is now in the '%s' state (health: '%s')",
 		ev.Timestamp.Format("2006-01-02 15:04:05"), ev.Kind, ev.GetName(), ev.GetState(), ev.GetHealthState())
 }
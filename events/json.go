@@ -0,0 +1,221 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON wire format of an Event.
+// It must be incremented whenever a change to the format is not
+// backwards compatible.
+const SchemaVersion = 1
+
+// wireEvent is the JSON representation of an Event:
+//
+//	{
+//	  "version": 1,
+//	  "id": "<event id>",
+//	  "timestamp": "2016-09-01T12:00:00Z",
+//	  "receivedAt": "2016-09-01T12:00:01Z",
+//	  "kind": "service",
+//	  "severity": "warning",
+//	  "previousState": "active",
+//	  "previousHealthState": "healthy",
+//	  "transition": { "initial": false, "stateChanged": false, "healthChanged": true },
+//	  "service": { ... }
+//	}
+//
+// Only the payload matching the event kind is included. Its key is
+// the name of the event kind.
+// The "flapping" flag of the transition is only included if it is set.
+// The "enrichment" object is only included if related resources were resolved.
+// The "transitioning" object ({"state", "message", "progress"}) is only included
+// if the resource is or was transitioning. "receivedAt" is missing from events
+// encoded by earlier releases.
+// The "envelope" object holds the metadata of the Rancher event and "raw" the
+// resource as received from Rancher, including fields not mapped to the payload.
+// Plugins sending events to external systems leave out "raw" by default by
+// clearing Event.Raw; it is kept in disk queues and dead letter files.
+type wireEvent struct {
+	Version             int                    `json:"version"`
+	ID                  string                 `json:"id"`
+	Timestamp           time.Time              `json:"timestamp"`
+	ReceivedAt          *time.Time             `json:"receivedAt,omitempty"`
+	Kind                EventKind              `json:"kind"`
+	Severity            Severity               `json:"severity,omitempty"`
+	PreviousState       InstanceState          `json:"previousState,omitempty"`
+	PreviousHealthState HealthState            `json:"previousHealthState,omitempty"`
+	Transition          wireTransition         `json:"transition"`
+	Transitioning       *Transitioning         `json:"transitioning,omitempty"`
+	Enrichment          *Enrichment            `json:"enrichment,omitempty"`
+	Envelope            *Envelope              `json:"envelope,omitempty"`
+	Raw                 map[string]interface{} `json:"raw,omitempty"`
+	Container           *Container             `json:"container,omitempty"`
+	Host                *Host                  `json:"host,omitempty"`
+	Service             *Service               `json:"service,omitempty"`
+	Stack               *Stack                 `json:"stack,omitempty"`
+	Volume              *Volume                `json:"volume,omitempty"`
+	LoadBalancer        *LoadBalancer          `json:"loadbalancer,omitempty"`
+	Certificate         *Certificate           `json:"certificate,omitempty"`
+	Registry            *Registry              `json:"registry,omitempty"`
+	StoragePool         *StoragePool           `json:"storagepool,omitempty"`
+	Agent               *Agent                 `json:"agent,omitempty"`
+	Project             *Project               `json:"project,omitempty"`
+}
+
+type wireTransition struct {
+	Initial       bool `json:"initial"`
+	StateChanged  bool `json:"stateChanged"`
+	HealthChanged bool `json:"healthChanged"`
+	Flapping      bool `json:"flapping,omitempty"`
+}
+
+// MarshalJSON encodes the event using the versioned wire format.
+func (ev Event) MarshalJSON() ([]byte, error) {
+	w := wireEvent{
+		Version:             SchemaVersion,
+		ID:                  ev.ID,
+		Timestamp:           ev.Timestamp.UTC(),
+		Kind:                ev.Kind,
+		Severity:            ev.Severity,
+		PreviousState:       ev.PreviousState,
+		PreviousHealthState: ev.PreviousHealthState,
+		Transition:          wireTransition(ev.Transition),
+	}
+	if !ev.ReceivedAt.IsZero() {
+		receivedAt := ev.ReceivedAt.UTC()
+		w.ReceivedAt = &receivedAt
+	}
+	if !ev.Transitioning.IsZero() {
+		w.Transitioning = &ev.Transitioning
+	}
+	if !ev.Enrichment.IsZero() {
+		w.Enrichment = &ev.Enrichment
+	}
+	if !ev.Envelope.IsZero() {
+		w.Envelope = &ev.Envelope
+	}
+	w.Raw = ev.Raw
+
+	switch ev.Kind {
+	case ContainerEvent:
+		w.Container = &ev.ContainerData
+	case HostEvent:
+		w.Host = &ev.HostData
+	case ServiceEvent:
+		w.Service = &ev.ServiceData
+	case StackEvent:
+		w.Stack = &ev.StackData
+	case VolumeEvent:
+		w.Volume = &ev.VolumeData
+	case LoadBalancerEvent:
+		w.LoadBalancer = &ev.LoadBalancerData
+	case CertificateEvent:
+		w.Certificate = &ev.CertificateData
+	case RegistryEvent:
+		w.Registry = &ev.RegistryData
+	case StoragePoolEvent:
+		w.StoragePool = &ev.StoragePoolData
+	case AgentEvent:
+		w.Agent = &ev.AgentData
+	case ProjectEvent:
+		w.Project = &ev.ProjectData
+	default:
+		return nil, fmt.Errorf("Unknown event kind: %s", ev.Kind)
+	}
+
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON decodes an event encoded in the versioned wire format.
+func (ev *Event) UnmarshalJSON(data []byte) error {
+	var w wireEvent
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+
+	if w.Version < 1 || w.Version > SchemaVersion {
+		return fmt.Errorf("Unsupported event schema version: %d", w.Version)
+	}
+
+	decoded := Event{
+		ID:                  w.ID,
+		Timestamp:           w.Timestamp,
+		Kind:                w.Kind,
+		Severity:            w.Severity,
+		PreviousState:       w.PreviousState,
+		PreviousHealthState: w.PreviousHealthState,
+		Transition:          Transition(w.Transition),
+	}
+	if w.ReceivedAt != nil {
+		decoded.ReceivedAt = *w.ReceivedAt
+	}
+	if w.Transitioning != nil {
+		decoded.Transitioning = *w.Transitioning
+	}
+	if w.Enrichment != nil {
+		decoded.Enrichment = *w.Enrichment
+	}
+	if w.Envelope != nil {
+		decoded.Envelope = *w.Envelope
+	}
+	decoded.Raw = w.Raw
+
+	var ok bool
+	switch w.Kind {
+	case ContainerEvent:
+		if ok = w.Container != nil; ok {
+			decoded.ContainerData = *w.Container
+		}
+	case HostEvent:
+		if ok = w.Host != nil; ok {
+			decoded.HostData = *w.Host
+		}
+	case ServiceEvent:
+		if ok = w.Service != nil; ok {
+			decoded.ServiceData = *w.Service
+		}
+	case StackEvent:
+		if ok = w.Stack != nil; ok {
+			decoded.StackData = *w.Stack
+		}
+	case VolumeEvent:
+		if ok = w.Volume != nil; ok {
+			decoded.VolumeData = *w.Volume
+		}
+	case LoadBalancerEvent:
+		if ok = w.LoadBalancer != nil; ok {
+			decoded.LoadBalancerData = *w.LoadBalancer
+		}
+	case CertificateEvent:
+		if ok = w.Certificate != nil; ok {
+			decoded.CertificateData = *w.Certificate
+		}
+	case RegistryEvent:
+		if ok = w.Registry != nil; ok {
+			decoded.RegistryData = *w.Registry
+		}
+	case StoragePoolEvent:
+		if ok = w.StoragePool != nil; ok {
+			decoded.StoragePoolData = *w.StoragePool
+		}
+	case AgentEvent:
+		if ok = w.Agent != nil; ok {
+			decoded.AgentData = *w.Agent
+		}
+	case ProjectEvent:
+		if ok = w.Project != nil; ok {
+			decoded.ProjectData = *w.Project
+		}
+	default:
+		return fmt.Errorf("Unknown event kind: %s", w.Kind)
+	}
+
+	if !ok {
+		return fmt.Errorf("Missing '%s' payload for event %s", w.Kind, w.ID)
+	}
+
+	*ev = decoded
+	return nil
+}
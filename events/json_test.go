@@ -0,0 +1,124 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, kind := range AllEventKinds {
+		t.Run(string(kind), func(t *testing.T) {
+			ev, err := New("ev-1", kind, map[string]interface{}{
+				"id":          "1r1",
+				"name":        "web",
+				"state":       "active",
+				"healthState": "healthy",
+				"labels":      map[string]interface{}{"tier": "frontend"},
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			ev.Timestamp = time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+			ev.ReceivedAt = ev.Timestamp.Add(time.Second)
+			ev.Severity = SeverityWarning
+			ev.PreviousState = "inactive"
+			ev.PreviousHealthState = StateUnhealthy
+			ev.Transition = Transition{StateChanged: true, Flapping: true}
+			ev.Transitioning = Transitioning{State: TransitioningYes, Message: "Starting", Progress: 50}
+			ev.Enrichment = Enrichment{HostName: "prod-03", StackName: "shop"}
+			ev.Envelope = Envelope{Name: "resource.change", ID: "1", ResourceType: string(kind), Time: 1472731200000}
+
+			data, err := json.Marshal(ev)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var decoded Event
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, ev) {
+				t.Errorf("decoded event differs\n got: %+v\nwant: %+v", decoded, ev)
+			}
+		})
+	}
+}
+
+func TestJSONOnlyIncludesPayloadOfKind(t *testing.T) {
+	ev, err := New("ev-1", HostEvent, map[string]interface{}{"id": "1h1", "name": "prod-03"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc["host"]; !ok {
+		t.Error("host payload is missing")
+	}
+	for _, key := range []string{"container", "service", "flapping", "enrichment", "transitioning", "envelope"} {
+		if _, ok := doc[key]; ok {
+			t.Errorf("unexpected key %q in %s", key, data)
+		}
+	}
+}
+
+func TestJSONRejectsInvalidDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"unknown version", `{"version": 2, "id": "1", "kind": "host", "host": {"id": "1h1"}}`, "schema version: 2"},
+		{"missing version", `{"id": "1", "kind": "host", "host": {"id": "1h1"}}`, "schema version: 0"},
+		{"unknown kind", `{"version": 1, "id": "1", "kind": "node", "node": {}}`, "Unknown event kind"},
+		{"missing payload", `{"version": 1, "id": "1", "kind": "host", "service": {"id": "1s1"}}`, "Missing 'host' payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ev Event
+			err := json.Unmarshal([]byte(tt.doc), &ev)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Unmarshal error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// An event as encoded by the first release of the format, before
+// receivedAt, severity, transitioning, enrichment, envelope and raw were added.
+const v1Event = `{
+  "version": 1,
+  "id": "ev-1",
+  "timestamp": "2016-09-01T12:00:00Z",
+  "kind": "service",
+  "previousState": "active",
+  "previousHealthState": "healthy",
+  "transition": {"initial": false, "stateChanged": false, "healthChanged": true},
+  "service": {"id": "1s1", "name": "web", "scale": 2, "state": "active", "healthState": "unhealthy"}
+}`
+
+func TestJSONDecodesFirstVersion(t *testing.T) {
+	var ev Event
+	if err := json.Unmarshal([]byte(v1Event), &ev); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if ev.Kind != ServiceEvent || ev.GetName() != "web" || ev.GetHealthState() != StateUnhealthy {
+		t.Errorf("decoded event = %+v", ev)
+	}
+	if !ev.Transition.HealthChanged || ev.Transition.Flapping {
+		t.Errorf("Transition = %+v", ev.Transition)
+	}
+	if !ev.ReceivedAt.IsZero() || ev.Latency() != 0 {
+		t.Errorf("ReceivedAt = %v, want zero", ev.ReceivedAt)
+	}
+	if ev.Severity != "" || !ev.Transitioning.IsZero() || !ev.Enrichment.IsZero() || !ev.Envelope.IsZero() || ev.Raw != nil {
+		t.Errorf("fields added later are set: %+v", ev)
+	}
+}
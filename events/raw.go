@@ -0,0 +1,111 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Envelope holds the metadata of the Rancher event the Event was created from.
+type Envelope struct {
+	Name          string   `json:"name,omitempty"` // e.g. "resource.change"
+	ID            string   `json:"id,omitempty"`
+	ResourceID    string   `json:"resourceId,omitempty"`
+	ResourceType  string   `json:"resourceType,omitempty"` // Rancher resource type, e.g. "loadBalancerService"
+	Publisher     string   `json:"publisher,omitempty"`
+	ReplyTo       string   `json:"replyTo,omitempty"`
+	PreviousNames []string `json:"previousNames,omitempty"`
+	Time          int64    `json:"time,omitempty"` // milliseconds since the epoch
+}
+
+// IsZero returns true if the event was not created from a Rancher event.
+func (e Envelope) IsZero() bool {
+	return e.Name == "" && e.ID == ""
+}
+
+// RawField returns the value at the given dot separated path of the raw
+// resource, e.g. "launchConfig.imageUuid". Elements of lists are addressed
+// by their index, e.g. "publicEndpoints.0.port".
+func (ev Event) RawField(path string) (interface{}, bool) {
+	var value interface{} = ev.Raw
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, value != nil
+}
+
+// RawString returns the raw field at the given path as a string.
+// Numbers and booleans are formatted, other values yield an empty string.
+func (ev Event) RawString(path string) string {
+	value, _ := ev.RawField(path)
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// RawInt returns the raw field at the given path as an integer or 0.
+func (ev Event) RawInt(path string) int {
+	value, _ := ev.RawField(path)
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return 0
+}
+
+// RawBool returns the raw field at the given path as a boolean.
+func (ev Event) RawBool(path string) bool {
+	value, _ := ev.RawField(path)
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// RawMap returns the raw field at the given path if it is an object.
+func (ev Event) RawMap(path string) map[string]interface{} {
+	value, _ := ev.RawField(path)
+	m, _ := value.(map[string]interface{})
+	return m
+}
+
+// RawList returns the raw field at the given path if it is a list.
+func (ev Event) RawList(path string) []interface{} {
+	value, _ := ev.RawField(path)
+	l, _ := value.([]interface{})
+	return l
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const rawResource = `{
+	"id": "1s1",
+	"scale": 3,
+	"ratio": 0.5,
+	"startOnCreate": true,
+	"retainIp": "true",
+	"count": "7",
+	"launchConfig": {
+		"imageUuid": "docker:nginx:1.11",
+		"labels": {"team": "payments"},
+		"ports": ["80:80/tcp"]
+	},
+	"publicEndpoints": [
+		{"ipAddress": "10.0.0.1", "port": 8080},
+		{"ipAddress": "10.0.0.2", "port": 8081}
+	],
+	"description": null
+}`
+
+func rawEvent(t *testing.T) Event {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(rawResource), &data); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := New("ev-1", ServiceEvent, data)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return ev
+}
+
+func TestRawField(t *testing.T) {
+	ev := rawEvent(t)
+	tests := []struct {
+		path  string
+		value interface{}
+		ok    bool
+	}{
+		{"id", "1s1", true},
+		{"launchConfig.imageUuid", "docker:nginx:1.11", true},
+		{"launchConfig.labels.team", "payments", true},
+		{"launchConfig.ports.0", "80:80/tcp", true},
+		{"publicEndpoints.1.port", float64(8081), true},
+		{"launchConfig", ev.Raw["launchConfig"], true},
+		{"missing", nil, false},
+		{"missing.imageUuid", nil, false},
+		{"launchConfig.missing.team", nil, false},
+		{"id.length", nil, false},
+		{"publicEndpoints.2.port", nil, false},
+		{"publicEndpoints.-1.port", nil, false},
+		{"publicEndpoints.first.port", nil, false},
+		{"description", nil, false},
+		{"", nil, false},
+	}
+
+	for _, tt := range tests {
+		value, ok := ev.RawField(tt.path)
+		if ok != tt.ok || !reflect.DeepEqual(value, tt.value) {
+			t.Errorf("RawField(%q) = %v, %v, want %v, %v", tt.path, value, ok, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestRawFieldWithoutRawResource(t *testing.T) {
+	var ev Event
+	if value, ok := ev.RawField("id"); ok {
+		t.Errorf("RawField returned %v for an event without raw resource", value)
+	}
+}
+
+func TestRawAccessors(t *testing.T) {
+	ev := rawEvent(t)
+
+	texts := map[string]string{
+		"id":                     "1s1",
+		"scale":                  "3",
+		"ratio":                  "0.5",
+		"startOnCreate":          "true",
+		"launchConfig":           "",
+		"launchConfig.ports":     "",
+		"publicEndpoints.0.port": "8080",
+		"missing.path":           "",
+	}
+	for path, want := range texts {
+		if got := ev.RawString(path); got != want {
+			t.Errorf("RawString(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	ints := map[string]int{
+		"scale":                  3,
+		"ratio":                  0,
+		"count":                  7,
+		"publicEndpoints.1.port": 8081,
+		"id":                     0,
+		"startOnCreate":          0,
+		"launchConfig":           0,
+		"missing":                0,
+	}
+	for path, want := range ints {
+		if got := ev.RawInt(path); got != want {
+			t.Errorf("RawInt(%q) = %d, want %d", path, got, want)
+		}
+	}
+
+	bools := map[string]bool{
+		"startOnCreate": true,
+		"retainIp":      true,
+		"id":            false,
+		"scale":         false,
+		"missing":       false,
+	}
+	for path, want := range bools {
+		if got := ev.RawBool(path); got != want {
+			t.Errorf("RawBool(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	if m := ev.RawMap("launchConfig.labels"); m["team"] != "payments" {
+		t.Errorf("RawMap(launchConfig.labels) = %v", m)
+	}
+	for _, path := range []string{"id", "launchConfig.ports", "missing"} {
+		if m := ev.RawMap(path); m != nil {
+			t.Errorf("RawMap(%q) = %v, want nil", path, m)
+		}
+	}
+
+	if l := ev.RawList("publicEndpoints"); len(l) != 2 {
+		t.Errorf("RawList(publicEndpoints) has %d elements, want 2", len(l))
+	}
+	for _, path := range []string{"id", "launchConfig", "missing"} {
+		if l := ev.RawList(path); l != nil {
+			t.Errorf("RawList(%q) = %v, want nil", path, l)
+		}
+	}
+}
+
+func TestRawIntAfterJSONRoundTrip(t *testing.T) {
+	ev, err := New("ev-1", ServiceEvent, map[string]interface{}{
+		"scale":     3,
+		"createdTS": int64(1472731200000),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if scale := ev.RawInt("scale"); scale != 3 {
+		t.Fatalf("RawInt(scale) = %d before the round trip, want 3", scale)
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded Event
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if _, ok := decoded.Raw["scale"].(float64); !ok {
+		t.Fatalf("scale decoded as %T, want float64", decoded.Raw["scale"])
+	}
+	if scale := decoded.RawInt("scale"); scale != 3 {
+		t.Errorf("RawInt(scale) = %d, want 3", scale)
+	}
+	if ts := decoded.RawInt("createdTS"); ts != 1472731200000 {
+		t.Errorf("RawInt(createdTS) = %d, want 1472731200000", ts)
+	}
+	if s := decoded.RawString("scale"); s != "3" {
+		t.Errorf("RawString(scale) = %q, want \"3\"", s)
+	}
+}
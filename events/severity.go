@@ -0,0 +1,75 @@
+package events
+
+import (
+	"fmt"
+)
+
+// Severity tells how urgent an event is. It is used by the plugins to
+// route and color notifications consistently.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+	SeverityResolved Severity = "resolved" // a resource recovered from a warning or critical condition
+)
+
+// ParseSeverity returns the severity with the given name.
+func ParseSeverity(name string) (Severity, error) {
+	switch s := Severity(name); s {
+	case SeverityInfo, SeverityWarning, SeverityCritical, SeverityResolved:
+		return s, nil
+	}
+	return "", fmt.Errorf("Unknown severity '%s'", name)
+}
+
+// Problem returns true for the warning and critical severities.
+func (s Severity) Problem() bool {
+	return s == SeverityWarning || s == SeverityCritical
+}
+
+// Classify returns the default severity of the event derived from its
+// kind, state, health state and transition:
+//
+//	critical  the resource is unhealthy, in the error state, its agent is
+//	          disconnected or its last transition failed
+//	warning   the resource is degraded or flapping, a container stopped,
+//	          a host became inactive or an agent is reconnecting
+//	resolved  the resource recovered from a warning or critical condition
+//	info      anything else
+func Classify(ev Event) Severity {
+	current := stateSeverity(ev.Kind, ev.GetState(), ev.GetHealthState())
+	if ev.Transitioning.Failed() {
+		current = SeverityCritical
+	}
+	if ev.Transition.Flapping && current != SeverityCritical {
+		current = SeverityWarning
+	}
+	if current.Problem() || ev.Transition.Initial {
+		return current
+	}
+	if state := ev.GetState(); state == StateRemoved || state == StatePurged {
+		return SeverityInfo
+	}
+
+	if stateSeverity(ev.Kind, ev.PreviousState, ev.PreviousHealthState).Problem() {
+		return SeverityResolved
+	}
+	return SeverityInfo
+}
+
+// stateSeverity classifies a state and health state of a resource of the given kind.
+func stateSeverity(kind EventKind, state InstanceState, health HealthState) Severity {
+	switch {
+	case state == StateError, health == StateUnhealthy,
+		kind == AgentEvent && state == AgentDisconnected:
+		return SeverityCritical
+	case health == StateDegraded,
+		kind == ContainerEvent && state == ContainerStopped,
+		kind == HostEvent && state == HostInactive,
+		kind == AgentEvent && state == AgentReconnecting:
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
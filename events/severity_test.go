@@ -0,0 +1,69 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name          string
+		kind          EventKind
+		state         InstanceState
+		health        HealthState
+		prevState     InstanceState
+		prevHealth    HealthState
+		transitioning string
+		transition    Transition
+		want          Severity
+	}{
+		{"running container", ContainerEvent, "running", "healthy", "starting", "initializing", "", Transition{StateChanged: true}, SeverityInfo},
+		{"unhealthy container", ContainerEvent, "running", "unhealthy", "running", "healthy", "", Transition{HealthChanged: true}, SeverityCritical},
+		{"container in error state", ContainerEvent, "error", "", "running", "", "", Transition{StateChanged: true}, SeverityCritical},
+		{"failed transition", ContainerEvent, "running", "healthy", "running", "healthy", TransitioningError, Transition{}, SeverityCritical},
+		{"stopped container", ContainerEvent, "stopped", "", "running", "", "", Transition{StateChanged: true}, SeverityWarning},
+		{"stopped service", ServiceEvent, "stopped", "", "active", "", "", Transition{StateChanged: true}, SeverityInfo},
+		{"degraded service", ServiceEvent, "active", "degraded", "active", "healthy", "", Transition{HealthChanged: true}, SeverityWarning},
+		{"inactive host", HostEvent, "inactive", "", "active", "", "", Transition{StateChanged: true}, SeverityWarning},
+		{"disconnected agent", AgentEvent, "disconnected", "", "active", "", "", Transition{StateChanged: true}, SeverityCritical},
+		{"reconnecting agent", AgentEvent, "reconnecting", "", "active", "", "", Transition{StateChanged: true}, SeverityWarning},
+		{"flapping container", ContainerEvent, "running", "healthy", "stopped", "", "", Transition{StateChanged: true, Flapping: true}, SeverityWarning},
+		{"flapping unhealthy container", ContainerEvent, "running", "unhealthy", "running", "healthy", "", Transition{Flapping: true}, SeverityCritical},
+		{"recovered health", ContainerEvent, "running", "healthy", "running", "unhealthy", "", Transition{HealthChanged: true}, SeverityResolved},
+		{"restarted container", ContainerEvent, "running", "", "stopped", "", "", Transition{StateChanged: true}, SeverityResolved},
+		{"reconnected agent", AgentEvent, "active", "", "disconnected", "", "", Transition{StateChanged: true}, SeverityResolved},
+		{"recovered from degraded to unhealthy", ServiceEvent, "active", "unhealthy", "active", "degraded", "", Transition{HealthChanged: true}, SeverityCritical},
+		{"new healthy container", ContainerEvent, "running", "healthy", "", "", "", Transition{Initial: true}, SeverityInfo},
+		{"new stopped container", ContainerEvent, "stopped", "", "", "", "", Transition{Initial: true}, SeverityWarning},
+		{"removed stopped container", ContainerEvent, "removed", "", "stopped", "", "", Transition{StateChanged: true}, SeverityInfo},
+		{"purged unhealthy container", ContainerEvent, "purged", "", "running", "unhealthy", "", Transition{StateChanged: true}, SeverityInfo},
+	}
+
+	for _, tt := range tests {
+		ev, err := New("ev-1", tt.kind, map[string]interface{}{
+			"state":         string(tt.state),
+			"healthState":   string(tt.health),
+			"transitioning": tt.transitioning,
+		})
+		if err != nil {
+			t.Fatalf("%s: New: %v", tt.name, err)
+		}
+		ev.PreviousState = tt.prevState
+		ev.PreviousHealthState = tt.prevHealth
+		ev.Transition = tt.transition
+
+		if got := Classify(ev); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, name := range []string{"info", "warning", "critical", "resolved"} {
+		if s, err := ParseSeverity(name); err != nil || string(s) != name {
+			t.Errorf("ParseSeverity(%q) = %q, %v", name, s, err)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("ParseSeverity accepted an unknown severity")
+	}
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"strconv"
+	"time"
+)
+
+// Transitioning state values reported by Rancher.
+const (
+	TransitioningYes   = "yes"
+	TransitioningNo    = "no"
+	TransitioningError = "error"
+)
+
+// Transitioning describes a change of the resource that is still in progress
+// or failed, e.g. a service waiting for its health checks to pass.
+type Transitioning struct {
+	State    string `json:"state,omitempty"`    // yes, no or error
+	Message  string `json:"message,omitempty"`  // e.g. "Waiting for health check"
+	Progress int    `json:"progress,omitempty"` // percent, 0 if unknown
+}
+
+// IsZero returns true if no transitioning information is available.
+func (t Transitioning) IsZero() bool {
+	return t == Transitioning{}
+}
+
+// InProgress returns true if the resource is transitioning.
+func (t Transitioning) InProgress() bool {
+	return t.State == TransitioningYes
+}
+
+// Failed returns true if the last transition of the resource failed.
+func (t Transitioning) Failed() bool {
+	return t.State == TransitioningError
+}
+
+// Latency returns the time between Rancher generating the event and
+// eventbridge receiving it. It is zero if the source time is unknown.
+func (ev Event) Latency() time.Duration {
+	if ev.ReceivedAt.IsZero() || !ev.ReceivedAt.After(ev.Timestamp) {
+		return 0
+	}
+	return ev.ReceivedAt.Sub(ev.Timestamp)
+}
+
+// parseTransitioning reads the transitioning fields of a Rancher resource.
+func parseTransitioning(resourceData map[string]interface{}) Transitioning {
+	var t Transitioning
+	t.State, _ = resourceData["transitioning"].(string)
+	t.Message, _ = resourceData["transitioningMessage"].(string)
+	switch p := resourceData["transitioningProgress"].(type) {
+	case float64:
+		t.Progress = int(p)
+	case int:
+		t.Progress = p
+	case string:
+		t.Progress, _ = strconv.Atoi(p)
+	}
+	// Rancher reports "no" for settled resources, which carries no information
+	if t.State == TransitioningNo && t.Message == "" && t.Progress == 0 {
+		t.State = ""
+	}
+	return t
+}
@@ -5,60 +5,162 @@ type HealthState string
 type InstanceState string
 
 type Stack struct {
-	ID          string
-	UUID        string
-	Name        string
-	Description string
-	State       InstanceState
-	HealthState HealthState
+	ID          string        `json:"id"`
+	UUID        string        `json:"uuid,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	State       InstanceState `json:"state"`
+	HealthState HealthState   `json:"healthState,omitempty"`
+	AccountID   string        `json:"accountId,omitempty"`
 }
 
 type Service struct {
-	ID          string
-	UUID        string
-	Version     string
-	Name        string
-	Description string
-	Scale       int
-	State       InstanceState
-	HealthState HealthState
-	Metadata    map[string]interface{}
-	Fqdn        string
-	Vip         string
+	ID            string                 `json:"id"`
+	UUID          string                 `json:"uuid,omitempty"`
+	Version       string                 `json:"version,omitempty"`
+	Name          string                 `json:"name"`
+	Description   string                 `json:"description,omitempty"`
+	Scale         int                    `json:"scale"`
+	State         InstanceState          `json:"state"`
+	HealthState   HealthState            `json:"healthState,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Fqdn          string                 `json:"fqdn,omitempty"`
+	Vip           string                 `json:"vip,omitempty"`
+	LaunchConfig  *LaunchConfig          `json:"launchConfig,omitempty"`
+	EnvironmentID string                 `json:"environmentId,omitempty"`
+	AccountID     string                 `json:"accountId,omitempty"`
+}
+
+// LaunchConfig holds the parts of a service's launch config passed on in events.
+type LaunchConfig struct {
+	ImageUUID string            `json:"imageUuid,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
 }
 
 type Container struct {
-	ID               string
-	UUID             string
-	Version          string
-	Name             string
-	Description      string
-	ServiceName      string
-	StackName        string
-	State            InstanceState
-	HealthState      HealthState
-	Environment      map[string]string
-	Labels           map[string]string
-	Metadata         map[string]interface{}
-	PrimaryIpAddress string
-	Ports            []string
-	ImageUUID        string
-	HostID           string
+	ID               string                 `json:"id"`
+	UUID             string                 `json:"uuid,omitempty"`
+	Version          string                 `json:"version,omitempty"`
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description,omitempty"`
+	ServiceName      string                 `json:"serviceName,omitempty"`
+	StackName        string                 `json:"stackName,omitempty"`
+	State            InstanceState          `json:"state"`
+	HealthState      HealthState            `json:"healthState,omitempty"`
+	Environment      map[string]string      `json:"environment,omitempty"`
+	Labels           map[string]string      `json:"labels,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	PrimaryIpAddress string                 `json:"primaryIpAddress,omitempty"`
+	Ports            []string               `json:"ports,omitempty"`
+	ImageUUID        string                 `json:"imageUuid,omitempty"`
+	HostID           string                 `json:"hostId,omitempty"`
+	AccountID        string                 `json:"accountId,omitempty"`
 }
 
 type Host struct {
-	ID              string
-	UUID            string
-	Name            string
-	Description     string
-	State           InstanceState
-	AgentState      string
-	Labels          map[string]string
-	Hostname        string
-	PublicEndpoints []Endpoints
+	ID              string            `json:"id"`
+	UUID            string            `json:"uuid,omitempty"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description,omitempty"`
+	State           InstanceState     `json:"state"`
+	AgentState      string            `json:"agentState,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Hostname        string            `json:"hostname,omitempty"`
+	PublicEndpoints []Endpoints       `json:"publicEndpoints,omitempty"`
+	AccountID       string            `json:"accountId,omitempty"`
 }
 
 type Endpoints struct {
-	IPAddress string
-	Port      int
+	IPAddress string `json:"ipAddress"`
+	Port      int    `json:"port"`
+}
+
+type Volume struct {
+	ID          string        `json:"id"`
+	UUID        string        `json:"uuid,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	State       InstanceState `json:"state"`
+	Driver      string        `json:"driver,omitempty"`
+	ExternalID  string        `json:"externalId,omitempty"`
+	InstanceID  string        `json:"instanceId,omitempty"`
+	AccessMode  string        `json:"accessMode,omitempty"`
+	URI         string        `json:"uri,omitempty"`
+	AccountID   string        `json:"accountId,omitempty"`
+}
+
+type LoadBalancer struct {
+	ID              string            `json:"id"`
+	UUID            string            `json:"uuid,omitempty"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description,omitempty"`
+	Scale           int               `json:"scale"`
+	State           InstanceState     `json:"state"`
+	HealthState     HealthState       `json:"healthState,omitempty"`
+	Fqdn            string            `json:"fqdn,omitempty"`
+	Vip             string            `json:"vip,omitempty"`
+	PublicEndpoints []Endpoints       `json:"publicEndpoints,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	LaunchConfig    *LaunchConfig     `json:"launchConfig,omitempty"`
+	EnvironmentID   string            `json:"environmentId,omitempty"`
+	AccountID       string            `json:"accountId,omitempty"`
+}
+
+type Certificate struct {
+	ID                      string        `json:"id"`
+	UUID                    string        `json:"uuid,omitempty"`
+	Name                    string        `json:"name"`
+	Description             string        `json:"description,omitempty"`
+	State                   InstanceState `json:"state"`
+	CN                      string        `json:"cn,omitempty"`
+	Issuer                  string        `json:"issuer,omitempty"`
+	IssuedAt                string        `json:"issuedAt,omitempty"`
+	ExpiresAt               string        `json:"expiresAt,omitempty"`
+	SubjectAlternativeNames []string      `json:"subjectAlternativeNames,omitempty"`
+	Algorithm               string        `json:"algorithm,omitempty"`
+	KeySize                 int           `json:"keySize,omitempty"`
+	SerialNumber            string        `json:"serialNumber,omitempty"`
+	CertFingerprint         string        `json:"certFingerprint,omitempty"`
+	AccountID               string        `json:"accountId,omitempty"`
+}
+
+type Registry struct {
+	ID            string        `json:"id"`
+	UUID          string        `json:"uuid,omitempty"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description,omitempty"`
+	State         InstanceState `json:"state"`
+	ServerAddress string        `json:"serverAddress,omitempty"`
+	DriverName    string        `json:"driverName,omitempty"`
+	AccountID     string        `json:"accountId,omitempty"`
+}
+
+type StoragePool struct {
+	ID          string        `json:"id"`
+	UUID        string        `json:"uuid,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	State       InstanceState `json:"state"`
+	DriverName  string        `json:"driverName,omitempty"`
+	ExternalID  string        `json:"externalId,omitempty"`
+	AccountID   string        `json:"accountId,omitempty"`
+}
+
+type Agent struct {
+	ID          string        `json:"id"`
+	UUID        string        `json:"uuid,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	State       InstanceState `json:"state"`
+	URI         string        `json:"uri,omitempty"`
+	AccountID   string        `json:"accountId,omitempty"`
+}
+
+type Project struct {
+	ID            string        `json:"id"`
+	UUID          string        `json:"uuid,omitempty"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description,omitempty"`
+	State         InstanceState `json:"state"`
+	Orchestration string        `json:"orchestration,omitempty"`
 }
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"github.com/janeczku/eventbridge/events"
+)
+
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	boolValue
+	listValue
+	mapValue
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case stringValue:
+		return "string"
+	case boolValue:
+		return "boolean"
+	case listValue:
+		return "list"
+	case mapValue:
+		return "map"
+	}
+	return "unknown"
+}
+
+type node interface {
+	kind() valueKind
+	eval(ev *events.Event) interface{}
+}
+
+type literalNode struct {
+	k   valueKind
+	val interface{}
+}
+
+func (n *literalNode) kind() valueKind                   { return n.k }
+func (n *literalNode) eval(ev *events.Event) interface{} { return n.val }
+
+type fieldNode struct {
+	name  string
+	field field
+}
+
+func (n *fieldNode) kind() valueKind                   { return n.field.kind }
+func (n *fieldNode) eval(ev *events.Event) interface{} { return n.field.get(ev) }
+
+type indexNode struct {
+	field *fieldNode
+	key   string
+}
+
+func (n *indexNode) kind() valueKind { return stringValue }
+func (n *indexNode) eval(ev *events.Event) interface{} {
+	switch m := n.field.eval(ev).(type) {
+	case map[string]string:
+		return m[n.key]
+	case func(string) string:
+		return m(n.key)
+	}
+	return ""
+}
+
+type eqNode struct {
+	left, right node
+}
+
+func (n *eqNode) kind() valueKind { return boolValue }
+func (n *eqNode) eval(ev *events.Event) interface{} {
+	return n.left.eval(ev) == n.right.eval(ev)
+}
+
+type inNode struct {
+	left, right node
+}
+
+func (n *inNode) kind() valueKind { return boolValue }
+func (n *inNode) eval(ev *events.Event) interface{} {
+	val, _ := n.left.eval(ev).(string)
+	list, _ := n.right.eval(ev).([]string)
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) kind() valueKind { return boolValue }
+func (n *notNode) eval(ev *events.Event) interface{} {
+	return !n.operand.eval(ev).(bool)
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n *andNode) kind() valueKind { return boolValue }
+func (n *andNode) eval(ev *events.Event) interface{} {
+	return n.left.eval(ev).(bool) && n.right.eval(ev).(bool)
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n *orNode) kind() valueKind { return boolValue }
+func (n *orNode) eval(ev *events.Event) interface{} {
+	return n.left.eval(ev).(bool) || n.right.eval(ev).(bool)
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"github.com/janeczku/eventbridge/events"
+)
+
+type field struct {
+	kind valueKind
+	get  func(ev *events.Event) interface{}
+}
+
+// fields maps the identifiers usable in filter expressions
+// to accessors on the event.
+var fields = map[string]field{
+	"id": {stringValue, func(ev *events.Event) interface{} {
+		return ev.ID
+	}},
+	"kind": {stringValue, func(ev *events.Event) interface{} {
+		return string(ev.Kind)
+	}},
+	"name": {stringValue, func(ev *events.Event) interface{} {
+		return ev.GetName()
+	}},
+	"state": {stringValue, func(ev *events.Event) interface{} {
+		return string(ev.GetState())
+	}},
+	"health": {stringValue, func(ev *events.Event) interface{} {
+		return string(ev.GetHealthState())
+	}},
+	"previous_state": {stringValue, func(ev *events.Event) interface{} {
+		return string(ev.PreviousState)
+	}},
+	"previous_health": {stringValue, func(ev *events.Event) interface{} {
+		return string(ev.PreviousHealthState)
+	}},
+	"changed": {boolValue, func(ev *events.Event) interface{} {
+		return ev.Transition.Changed()
+	}},
+	"state_changed": {boolValue, func(ev *events.Event) interface{} {
+		return ev.Transition.StateChanged
+	}},
+	"health_changed": {boolValue, func(ev *events.Event) interface{} {
+		return ev.Transition.HealthChanged
+	}},
+	"flapping": {boolValue, func(ev *events.Event) interface{} {
+		return ev.Transition.Flapping
+	}},
+	"severity": {stringValue, func(ev *events.Event) interface{} {
+		return string(ev.Severity)
+	}},
+	"transitioning": {stringValue, func(ev *events.Event) interface{} {
+		return ev.Transitioning.State
+	}},
+	"transitioning_message": {stringValue, func(ev *events.Event) interface{} {
+		return ev.Transitioning.Message
+	}},
+	"stack": {stringValue, func(ev *events.Event) interface{} {
+		return ev.GetStackName()
+	}},
+	"service": {stringValue, func(ev *events.Event) interface{} {
+		return ev.GetServiceName()
+	}},
+	"host": {stringValue, func(ev *events.Event) interface{} {
+		return ev.Enrichment.HostName
+	}},
+	"project": {stringValue, func(ev *events.Event) interface{} {
+		return ev.Enrichment.ProjectName
+	}},
+	"labels": {mapValue, func(ev *events.Event) interface{} {
+		return ev.GetLabels()
+	}},
+	"raw": {mapValue, func(ev *events.Event) interface{} {
+		return ev.RawString
+	}},
+}
@@ -0,0 +1,56 @@
+// Package filter implements the expression language used to select
+// the events that are passed to a plugin.
+//
+// An expression compares event fields with string literals or lists:
+//
+//	kind == "service" && health in ["unhealthy", "degraded"] && labels["team"] == "payments"
+//
+// Supported operators are ==, !=, in, not in, && (and), || (or) and ! (not).
+// Available string fields are id, kind, name, state, health, previous_state,
+// previous_health, severity, transitioning, transitioning_message, stack,
+// service, host and project. The boolean fields
+// changed, state_changed, health_changed and flapping describe the state
+// transition. Labels are accessed with labels["<key>"]; the labels of services
+// and load balancers are taken from their launch config. Fields of the raw
+// resource are accessed with raw["<path>"], e.g. raw["launchConfig.imageUuid"].
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+// Filter is a compiled filter expression.
+type Filter struct {
+	expr string
+	root node
+}
+
+// Compile parses the given expression and returns a Filter.
+func Compile(expr string) (*Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if len(expr) == 0 {
+		return nil, fmt.Errorf("Empty filter expression")
+	}
+
+	root, err := parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter expression '%s': %v", expr, err)
+	}
+
+	return &Filter{
+		expr: expr,
+		root: root,
+	}, nil
+}
+
+// Match returns true if the event satisfies the filter expression.
+func (f *Filter) Match(ev events.Event) bool {
+	return f.root.eval(&ev).(bool)
+}
+
+func (f *Filter) String() string {
+	return f.expr
+}
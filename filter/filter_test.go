@@ -0,0 +1,142 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+func testEvent(t *testing.T) events.Event {
+	ev, err := events.New("ev-1", events.ContainerEvent, map[string]interface{}{
+		"id":          "1i1",
+		"name":        "web-1",
+		"state":       "running",
+		"healthState": "unhealthy",
+		"labels":      map[string]interface{}{"team": "payments"},
+		"data": map[string]interface{}{
+			"fields": map[string]interface{}{"imageUuid": "docker:nginx"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ev.Transition = events.Transition{HealthChanged: true}
+	return ev
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "Empty filter expression"},
+		{`name == "web`, "Unterminated string at position 8"},
+		{`kind ==`, "Unexpected end of expression"},
+		{`== "container"`, "Unexpected '=='"},
+		{`kind == "container" &&`, "Unexpected end of expression"},
+		{`labels == "x"`, "Cannot compare map with string"},
+		{`labels`, "Expression does not evaluate to a boolean"},
+		{`labels[team] == "x"`, "Expected string at position 7"},
+		{`changed == "yes"`, "Cannot compare boolean with string"},
+		{`state && changed`, "Logical operators require boolean operands, got string"},
+		{`!state`, "Logical operators require boolean operands, got string"},
+		{`name == @`, "Unexpected character '@' at position 8"},
+		{`name["x"] == "y"`, "Field 'name' cannot be indexed"},
+		{`unknown == "x"`, "Unknown field 'unknown'"},
+		{`state in "running"`, "Operator 'in' requires a string and a list"},
+		{`state not "running"`, "Expected 'in'"},
+		{`state in ["running" "stopped"]`, "Expected ',' or ']'"},
+		{`(changed`, "Expected ')'"},
+		{`changed changed`, "Unexpected 'changed'"},
+		{`kind`, "Expression does not evaluate to a boolean"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.expr)
+		if err == nil {
+			t.Errorf("Compile(%q): expected an error", tt.expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Compile(%q): error %q does not contain %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{`kind == "container"`, true},
+		{`kind != "container"`, false},
+		{`name == 'web-1'`, true},
+
+		// && binds tighter than ||
+		{`kind == "host" && state == "running" || health == "unhealthy"`, true},
+		{`health == "unhealthy" || kind == "host" && state == "stopped"`, true},
+		{`kind == "host" && (state == "running" || health == "unhealthy")`, false},
+		{`(health == "unhealthy" || kind == "host") && state == "stopped"`, false},
+		{`kind == "host" and state == "running" or health == "unhealthy"`, true},
+
+		{`state in ["running", "starting"]`, true},
+		{`state in ["stopped"]`, false},
+		{`state in []`, false},
+		{`state not in ["stopped", "removed"]`, true},
+		{`state not in ["running"]`, false},
+		{`!(state in ["running"])`, false},
+
+		{`labels["team"] == "payments"`, true},
+		{`labels["team"] != "payments"`, false},
+		{`labels["missing"] == ""`, true},
+		{`labels["team"] in ["payments", "search"]`, true},
+		{`raw["data.fields.imageUuid"] == "docker:nginx"`, true},
+		{`raw["data.fields.missing"] == ""`, true},
+		{`raw["data.missing.imageUuid"] == ""`, true},
+		{`raw["healthState"] == "unhealthy"`, true},
+
+		{`changed`, true},
+		{`health_changed`, true},
+		{`state_changed`, false},
+		{`flapping`, false},
+		{`!flapping`, true},
+		{`!changed`, false},
+		{`!!changed`, true},
+		{`not state_changed`, true},
+		{`changed == true`, true},
+		{`flapping != false`, false},
+		{`health_changed && !state_changed`, true},
+	}
+
+	ev := testEvent(t)
+	for _, tt := range tests {
+		f, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(ev); got != tt.match {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.match)
+		}
+	}
+}
+
+func TestMatchLaunchConfigLabels(t *testing.T) {
+	ev, err := events.New("ev-1", events.ServiceEvent, map[string]interface{}{
+		"name": "web",
+		"launchConfig": map[string]interface{}{
+			"labels": map[string]interface{}{"team": "search"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	f, err := Compile(`labels["team"] == "search"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Match(ev) {
+		t.Error("expected service labels to be read from the launch config")
+	}
+}
@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIdent
+	tokString
+	tokLParen
+	tokRParen
+	tokLBrack
+	tokRBrack
+	tokComma
+	tokEq
+	tokNeq
+	tokAnd
+	tokOr
+	tokNot
+	tokIn
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%s'", t.val)
+}
+
+// lex splits a filter expression into tokens.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(input) {
+		c := rune(input[pos])
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", pos})
+			pos++
+		case c == '[':
+			tokens = append(tokens, token{tokLBrack, "[", pos})
+			pos++
+		case c == ']':
+			tokens = append(tokens, token{tokRBrack, "]", pos})
+			pos++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", pos})
+			pos++
+		case strings.HasPrefix(input[pos:], "=="):
+			tokens = append(tokens, token{tokEq, "==", pos})
+			pos += 2
+		case strings.HasPrefix(input[pos:], "!="):
+			tokens = append(tokens, token{tokNeq, "!=", pos})
+			pos += 2
+		case strings.HasPrefix(input[pos:], "&&"):
+			tokens = append(tokens, token{tokAnd, "&&", pos})
+			pos += 2
+		case strings.HasPrefix(input[pos:], "||"):
+			tokens = append(tokens, token{tokOr, "||", pos})
+			pos += 2
+		case c == '!':
+			tokens = append(tokens, token{tokNot, "!", pos})
+			pos++
+		case c == '"' || c == '\'':
+			end := strings.IndexRune(input[pos+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated string at position %d", pos)
+			}
+			tokens = append(tokens, token{tokString, input[pos+1 : pos+1+end], pos})
+			pos += end + 2
+		case isIdentRune(c):
+			start := pos
+			for pos < len(input) && isIdentRune(rune(input[pos])) {
+				pos++
+			}
+			word := input[start:pos]
+			switch word {
+			case "in":
+				tokens = append(tokens, token{tokIn, word, start})
+			case "not":
+				tokens = append(tokens, token{tokNot, word, start})
+			case "and":
+				tokens = append(tokens, token{tokAnd, word, start})
+			case "or":
+				tokens = append(tokens, token{tokOr, word, start})
+			default:
+				tokens = append(tokens, token{tokIdent, word, start})
+			}
+		default:
+			return nil, fmt.Errorf("Unexpected character '%c' at position %d", c, pos)
+		}
+	}
+	tokens = append(tokens, token{tokEOF, "", pos})
+	return tokens, nil
+}
+
+func isIdentRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
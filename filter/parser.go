@@ -0,0 +1,244 @@
+package filter
+
+import (
+	"fmt"
+)
+
+// parser is a recursive descent parser for filter expressions:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = operand [ ( "==" | "!=" ) operand | [ "not" ] "in" operand ]
+//	operand    = string | list | field [ "[" string "]" ] | "true" | "false" | "(" expr ")"
+//	list       = "[" [ string { "," string } ] "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(input string) (node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, fmt.Errorf("Unexpected %s at position %d", t, t.pos)
+	}
+
+	if n.kind() != boolValue {
+		return nil, fmt.Errorf("Expression does not evaluate to a boolean")
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(typ tokenType, what string) (token, error) {
+	t := p.next()
+	if t.typ != typ {
+		return t, fmt.Errorf("Expected %s at position %d, got %s", what, t.pos, t)
+	}
+	return t, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(left, right); err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(left, right); err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().typ == tokNot {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(operand); err != nil {
+			return nil, err
+		}
+		return &notNode{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch t.typ {
+	case tokEq, tokNeq:
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != right.kind() || left.kind() == listValue || left.kind() == mapValue {
+			return nil, fmt.Errorf("Cannot compare %s with %s at position %d", left.kind(), right.kind(), t.pos)
+		}
+		var n node = &eqNode{left, right}
+		if t.typ == tokNeq {
+			n = &notNode{n}
+		}
+		return n, nil
+	case tokNot, tokIn:
+		negate := false
+		if t.typ == tokNot {
+			p.next()
+			if _, err := p.expect(tokIn, "'in'"); err != nil {
+				return nil, err
+			}
+			negate = true
+		} else {
+			p.next()
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != stringValue || right.kind() != listValue {
+			return nil, fmt.Errorf("Operator 'in' requires a string and a list at position %d", t.pos)
+		}
+		var n node = &inNode{left, right}
+		if negate {
+			n = &notNode{n}
+		}
+		return n, nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.typ {
+	case tokString:
+		return &literalNode{stringValue, t.val}, nil
+	case tokLBrack:
+		return p.parseList()
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokIdent:
+		switch t.val {
+		case "true":
+			return &literalNode{boolValue, true}, nil
+		case "false":
+			return &literalNode{boolValue, false}, nil
+		}
+		f, ok := fields[t.val]
+		if !ok {
+			return nil, fmt.Errorf("Unknown field '%s' at position %d", t.val, t.pos)
+		}
+		n := &fieldNode{t.val, f}
+		if p.peek().typ != tokLBrack {
+			return n, nil
+		}
+		if f.kind != mapValue {
+			return nil, fmt.Errorf("Field '%s' cannot be indexed at position %d", t.val, t.pos)
+		}
+		p.next()
+		key, err := p.expect(tokString, "string")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRBrack, "']'"); err != nil {
+			return nil, err
+		}
+		return &indexNode{n, key.val}, nil
+	}
+
+	return nil, fmt.Errorf("Unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) parseList() (node, error) {
+	var items []string
+	if p.peek().typ == tokRBrack {
+		p.next()
+		return &literalNode{listValue, items}, nil
+	}
+	for {
+		t, err := p.expect(tokString, "string")
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, t.val)
+		t = p.next()
+		if t.typ == tokRBrack {
+			break
+		}
+		if t.typ != tokComma {
+			return nil, fmt.Errorf("Expected ',' or ']' at position %d, got %s", t.pos, t)
+		}
+	}
+	return &literalNode{listValue, items}, nil
+}
+
+func checkBool(nodes ...node) error {
+	for _, n := range nodes {
+		if n.kind() != boolValue {
+			return fmt.Errorf("Logical operators require boolean operands, got %s", n.kind())
+		}
+	}
+	return nil
+}
@@ -1,24 +1,101 @@
 package healthcheck
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/janeczku/eventbridge/metrics"
+
 	log "github.com/Sirupsen/logrus"
 )
 
+// Check is the result of a single readiness check.
+type Check struct {
+	Name    string                 `json:"name"`
+	Healthy bool                   `json:"healthy"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Checker reports the readiness of the application's components.
+type Checker interface {
+	Checks() []Check
+}
+
+type report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks,omitempty"`
+}
+
 func healthcheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "ok")
 }
 
-func StartHealthCheck(port int) error {
+func liveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "ok"})
+}
+
+func readiness(checker Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := report{
+			Status: "ok",
+			Checks: checker.Checks(),
+		}
+		code := http.StatusOK
+		for _, c := range rep.Checks {
+			if !c.Healthy {
+				rep.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+				break
+			}
+		}
+		writeReport(w, code, rep)
+	}
+}
+
+func reloadHandler(reload func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		log.Info("Configuration reload requested via admin endpoint")
+		if err := reload(); err != nil {
+			log.WithField("error", err).Error("Failed to reload configuration")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}
+}
+
+func writeReport(w http.ResponseWriter, code int, rep report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		log.WithField("error", err).Warn("Could not write health check response")
+	}
+}
+
+// StartHealthCheck serves the health checks and the metrics
+// of the given collector on the given port. If reload is not nil,
+// it is invoked by POST requests to /admin/reload.
+func StartHealthCheck(port int, checker Checker, collector metrics.Collector, reload func() error) error {
 	if port <= 0 || port > 65535 {
 		return fmt.Errorf("Invalid health check port number: %v", port)
 	}
 	http.HandleFunc("/healthcheck", healthcheck)
+	http.HandleFunc("/healthz", liveness)
+	http.HandleFunc("/readyz", readiness(checker))
+	http.Handle("/metrics", metrics.Handler(collector))
+	if reload != nil {
+		http.HandleFunc("/admin/reload", reloadHandler(reload))
+	}
 	p := ":" + strconv.Itoa(port)
-	log.Infof("Listening for health checks on 0.0.0.0%v/healthcheck", p)
+	log.Infof("Listening for health checks on 0.0.0.0%v/healthz and 0.0.0.0%v/readyz", p, p)
+	log.Infof("Serving metrics on 0.0.0.0%v/metrics", p)
 	err := http.ListenAndServe(p, nil)
 	return err
 }
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"sync"
+)
+
+// DefaultBuckets are the histogram upper bounds in seconds used for
+// measuring plugin latencies.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram.
+type HistogramSnapshot struct {
+	Buckets []float64
+	Counts  []uint64 // cumulative count per bucket
+	Sum     float64
+	Count   uint64
+}
+
+// NewHistogram returns a histogram with the given bucket upper bounds,
+// which must be sorted in increasing order.
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds a single observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	h.Lock()
+	defer h.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Snapshot returns a copy of the current histogram state.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.Lock()
+	defer h.Unlock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	return HistogramSnapshot{
+		Buckets: h.buckets,
+		Counts:  counts,
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+}
@@ -0,0 +1,103 @@
+// Package metrics exposes agent and plugin metrics in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+	Hist    = "histogram"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Labels are the label names and values of a sample.
+type Labels map[string]string
+
+// Collector writes its metrics to a Writer.
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Writer formats metric families and samples.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w}
+}
+
+// Family writes the HELP and TYPE lines of a metric family.
+// It must be followed by the samples of the family.
+func (w *Writer) Family(name, help, typ string) {
+	fmt.Fprintf(w.w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w.w, "# TYPE %s %s\n", name, typ)
+}
+
+// Sample writes a single sample.
+func (w *Writer) Sample(name string, labels Labels, value float64) {
+	fmt.Fprintf(w.w, "%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+// Histogram writes the bucket, sum and count samples of a histogram.
+func (w *Writer) Histogram(name string, labels Labels, h HistogramSnapshot) {
+	bucketLabels := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		bucketLabels[k] = v
+	}
+	for i, upper := range h.Buckets {
+		bucketLabels["le"] = formatValue(upper)
+		w.Sample(name+"_bucket", bucketLabels, float64(h.Counts[i]))
+	}
+	bucketLabels["le"] = "+Inf"
+	w.Sample(name+"_bucket", bucketLabels, float64(h.Count))
+	w.Sample(name+"_sum", labels, h.Sum)
+	w.Sample(name+"_count", labels, float64(h.Count))
+}
+
+// Handler returns an HTTP handler serving the metrics of the collector.
+func Handler(c Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		c.Collect(NewWriter(w))
+	})
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, labelValueEscaper.Replace(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
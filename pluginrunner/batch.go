@@ -0,0 +1,133 @@
+package pluginrunner
+
+import (
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/plugins"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// doBatchWork collects events from the queue until the batch size is reached
+// or the batch timeout expires after the first event and passes them to the plugin.
+func (r *PluginRunner) doBatchWork(bp plugins.BatchProcessor, deliveries <-chan delivery) {
+	log.WithField("plugin", r.Name).Debug("Plugin batch worker started")
+	defer r.waitGroup.Done()
+	for {
+		var batch []delivery
+		select {
+		case <-r.quitChan:
+			return
+		case d := <-deliveries:
+			batch = append(batch, d)
+		}
+
+		timeout := time.NewTimer(r.BatchTimeout)
+	collect:
+		for len(batch) < r.BatchSize {
+			select {
+			case <-r.quitChan:
+				timeout.Stop()
+				return
+			case d := <-deliveries:
+				batch = append(batch, d)
+			case <-timeout.C:
+				break collect
+			}
+		}
+		timeout.Stop()
+
+		evs := make([]events.Event, len(batch))
+		for i, d := range batch {
+			evs[i] = d.ev
+		}
+		if !r.processBatch(bp, evs) {
+			return
+		}
+		for _, d := range batch {
+			d.done()
+		}
+	}
+}
+
+// processBatch passes the batch to the plugin. Failed events are retried as a
+// batch according to the retry policy and passed to the dead letter sink once
+// they exhausted all attempts. It returns false if the runner was stopped
+// before the batch was processed.
+func (r *PluginRunner) processBatch(bp plugins.BatchProcessor, batch []events.Event) bool {
+	pending := batch
+	for attempt := 1; ; attempt++ {
+		log.WithFields(log.Fields{
+			"events":  len(pending),
+			"plugin":  r.Name,
+			"attempt": attempt,
+		}).Debug("Writing batch to plugin")
+
+		start := time.Now()
+		err := bp.ProcessBatch(pending)
+		r.Metrics.Latency.Observe(time.Since(start).Seconds())
+
+		failed := batchErrors(pending, err)
+		for i := 0; i < len(pending)-len(failed); i++ {
+			r.Metrics.record(false)
+		}
+		if len(failed) == 0 {
+			return true
+		}
+
+		log.WithFields(log.Fields{
+			"error":   err,
+			"plugin":  r.Name,
+			"failed":  len(failed),
+			"events":  len(pending),
+			"attempt": attempt,
+		}).Error("Error writing batch to plugin")
+
+		var retry []events.Event
+		for i, ev := range pending {
+			ferr, ok := failed[i]
+			if !ok {
+				continue
+			}
+			r.Metrics.record(true)
+			if attempt >= r.Retry.MaxAttempts || !plugins.IsRetryable(ferr) {
+				r.deadLetter(ev, ferr)
+				continue
+			}
+			retry = append(retry, ev)
+		}
+		if len(retry) == 0 {
+			return true
+		}
+
+		r.Metrics.inc(&r.Metrics.Retries)
+		select {
+		case <-r.quitChan:
+			return false
+		case <-time.After(r.Retry.Backoff(attempt)):
+		}
+		pending = retry
+	}
+}
+
+// batchErrors returns the errors of the failed events by their index in the batch.
+func batchErrors(batch []events.Event, err error) map[int]error {
+	if err == nil {
+		return nil
+	}
+	if berr, ok := err.(*plugins.BatchError); ok {
+		failed := make(map[int]error, len(berr.Errors))
+		for i, ferr := range berr.Errors {
+			if i >= 0 && i < len(batch) {
+				failed[i] = ferr
+			}
+		}
+		return failed
+	}
+	failed := make(map[int]error, len(batch))
+	for i := range batch {
+		failed[i] = err
+	}
+	return failed
+}
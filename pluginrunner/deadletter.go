@@ -0,0 +1,72 @@
+package pluginrunner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+// DeadLetterSink receives events that could not be processed by a
+// plugin after all delivery attempts.
+type DeadLetterSink interface {
+	WriteDeadLetter(plugin string, ev events.Event, err error) error
+}
+
+// deadLetter is a single line of a dead letter file.
+type deadLetter struct {
+	Timestamp time.Time    `json:"timestamp"`
+	Plugin    string       `json:"plugin"`
+	Error     string       `json:"error"`
+	Event     events.Event `json:"event"`
+}
+
+// FileSink appends dead letters as JSON lines to a file.
+// The file is opened when the first dead letter is written.
+type FileSink struct {
+	sync.Mutex
+	path string
+	file *os.File
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) WriteDeadLetter(plugin string, ev events.Event, err error) error {
+	line, jerr := json.Marshal(deadLetter{
+		Timestamp: time.Now().UTC(),
+		Plugin:    plugin,
+		Error:     err.Error(),
+		Event:     ev,
+	})
+	if jerr != nil {
+		return jerr
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.file == nil {
+		f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("Could not open dead letter file: %v", err)
+		}
+		s.file = f
+	}
+	_, werr := s.file.Write(append(line, '\n'))
+	return werr
+}
+
+func (s *FileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,116 @@
+package pluginrunner
+
+import (
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/plugins"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// doDigestWork collects the events from the queue and passes them as a digest
+// to the plugin every digest interval or as soon as the maximum number of
+// events is collected. The events are acknowledged once the digest has been
+// processed, so they are retried and dead lettered like single events.
+func (r *PluginRunner) doDigestWork(dp plugins.DigestProcessor, deliveries <-chan delivery) {
+	log.WithField("plugin", r.Name).Debug("Plugin digest worker started")
+	defer r.waitGroup.Done()
+
+	var tick <-chan time.Time
+	if r.DigestInterval > 0 {
+		ticker := time.NewTicker(r.DigestInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var digest []delivery
+	start := time.Now()
+	for {
+		select {
+		case <-r.quitChan:
+			r.flushDigest(dp, start, digest)
+			return
+		case d := <-deliveries:
+			digest = append(digest, d)
+			if r.DigestMaxEvents <= 0 || len(digest) < r.DigestMaxEvents {
+				continue
+			}
+		case <-tick:
+		case <-r.flushChan:
+		}
+		if len(digest) == 0 {
+			start = time.Now()
+			continue
+		}
+
+		if !r.flushDigest(dp, start, digest) {
+			return
+		}
+		digest = nil
+		start = time.Now()
+	}
+}
+
+// flushDigest passes the collected events as a digest to the plugin and
+// acknowledges them. When the runner is stopped, the digest is attempted
+// once and its events remain queued if it fails with a retryable error.
+func (r *PluginRunner) flushDigest(dp plugins.DigestProcessor, start time.Time, digest []delivery) bool {
+	if len(digest) == 0 {
+		return true
+	}
+	evs := make([]events.Event, len(digest))
+	for i, d := range digest {
+		evs[i] = d.ev
+	}
+	if !r.processDigest(dp, plugins.NewDigest(start, time.Now(), evs)) {
+		return false
+	}
+	for _, d := range digest {
+		d.done()
+	}
+	return true
+}
+
+// processDigest passes the digest to the plugin, retrying according to the
+// retry policy. If all attempts fail, its events are passed to the dead letter
+// sink. It returns false if the runner was stopped before the digest was processed.
+func (r *PluginRunner) processDigest(dp plugins.DigestProcessor, digest *plugins.Digest) bool {
+	for attempt := 1; ; attempt++ {
+		log.WithFields(log.Fields{
+			"events":  len(digest.Events),
+			"plugin":  r.Name,
+			"attempt": attempt,
+		}).Debug("Writing digest to plugin")
+
+		start := time.Now()
+		err := dp.ProcessDigest(digest)
+		r.Metrics.Latency.Observe(time.Since(start).Seconds())
+		if err == nil {
+			r.Metrics.record(false)
+			return true
+		}
+
+		r.Metrics.record(true)
+		log.WithFields(log.Fields{
+			"error":   err,
+			"plugin":  r.Name,
+			"events":  len(digest.Events),
+			"attempt": attempt,
+		}).Error("Error writing digest to plugin")
+
+		if attempt >= r.Retry.MaxAttempts || !plugins.IsRetryable(err) {
+			for _, ev := range digest.Events {
+				r.deadLetter(ev, err)
+			}
+			return true
+		}
+
+		r.Metrics.inc(&r.Metrics.Retries)
+		select {
+		case <-r.quitChan:
+			return false
+		case <-time.After(r.Retry.Backoff(attempt)):
+		}
+	}
+}
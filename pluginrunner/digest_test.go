@@ -0,0 +1,202 @@
+package pluginrunner
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/plugins"
+)
+
+// digestPlugin records the digests it receives and fails with the given errors first.
+type digestPlugin struct {
+	sync.Mutex
+	errs    []error
+	digests [][]events.Event
+}
+
+func (p *digestPlugin) Init() error  { return nil }
+func (p *digestPlugin) Close() error { return nil }
+func (p *digestPlugin) Name() string { return "digest" }
+func (p *digestPlugin) Process(ev events.Event) error {
+	return errors.New("Process called in digest mode")
+}
+
+func (p *digestPlugin) ProcessDigest(digest *plugins.Digest) error {
+	p.Lock()
+	defer p.Unlock()
+	p.digests = append(p.digests, digest.Events)
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (p *digestPlugin) received() [][]events.Event {
+	p.Lock()
+	defer p.Unlock()
+	return p.digests
+}
+
+type deadLetters struct {
+	sync.Mutex
+	evs []events.Event
+}
+
+func (d *deadLetters) WriteDeadLetter(plugin string, ev events.Event, err error) error {
+	d.Lock()
+	defer d.Unlock()
+	d.evs = append(d.evs, ev)
+	return nil
+}
+
+func (d *deadLetters) count() int {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.evs)
+}
+
+func newDigestRunner(t *testing.T, p *digestPlugin, newQueue QueueFactory) *PluginRunner {
+	r := New("digest", p, newQueue, map[events.EventKind]bool{events.ContainerEvent: true})
+	r.DigestInterval = time.Hour
+	r.DigestMaxEvents = 3
+	r.WorkerCount = 4
+	r.Retry = RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return r
+}
+
+func memoryQueue() (Queue, error) {
+	return NewEventQueue(10), nil
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDigestRetriesFailedDigest(t *testing.T) {
+	p := &digestPlugin{errs: []error{errors.New("unavailable")}}
+	r := newDigestRunner(t, p, memoryQueue)
+	defer r.Stop()
+
+	for i := 0; i < 3; i++ {
+		r.Write(queueEvent(i))
+	}
+	waitFor(t, "the digest", r.drained)
+
+	digests := p.received()
+	if len(digests) != 2 {
+		t.Fatalf("%d delivery attempts, want 2", len(digests))
+	}
+	for _, digest := range digests {
+		if len(digest) != 3 {
+			t.Errorf("Digest of %d events, want 3", len(digest))
+		}
+	}
+	if stats := r.Stats(); stats.Retries != 1 || stats.Errors != 1 || stats.Successes != 1 {
+		t.Errorf("Retries/Errors/Successes = %d/%d/%d, want 1/1/1", stats.Retries, stats.Errors, stats.Successes)
+	}
+}
+
+func TestDigestDeadLettersFailedEvents(t *testing.T) {
+	p := &digestPlugin{errs: []error{plugins.Permanent(errors.New("invalid"))}}
+	r := newDigestRunner(t, p, memoryQueue)
+	defer r.Stop()
+	sink := &deadLetters{}
+	r.DeadLetter = sink
+
+	for i := 0; i < 3; i++ {
+		r.Write(queueEvent(i))
+	}
+	waitFor(t, "the dead letters", func() bool { return sink.count() == 3 })
+	if attempts := len(p.received()); attempts != 1 {
+		t.Errorf("%d delivery attempts of a permanently failing digest, want 1", attempts)
+	}
+}
+
+func TestDigestIsFlushedOnStop(t *testing.T) {
+	p := &digestPlugin{}
+	r := newDigestRunner(t, p, memoryQueue)
+	r.Write(queueEvent(0))
+	r.Write(queueEvent(1))
+	waitFor(t, "the events to be collected", func() bool { return r.acks.inflight() == 2 })
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if digests := p.received(); len(digests) != 1 || len(digests[0]) != 2 {
+		t.Errorf("Digests = %v, want a single digest of 2 events", digests)
+	}
+}
+
+func TestDigestKeepsFailedEventsQueued(t *testing.T) {
+	dir := t.TempDir()
+	diskQueue := func() (Queue, error) {
+		return NewDiskQueue(dir, 10, SyncAlways)
+	}
+
+	p := &digestPlugin{errs: []error{errors.New("unavailable")}}
+	r := newDigestRunner(t, p, diskQueue)
+	r.Write(queueEvent(0))
+	r.Write(queueEvent(1))
+	waitFor(t, "the events to be collected", func() bool { return r.acks.inflight() == 2 })
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if attempts := len(p.received()); attempts != 1 {
+		t.Fatalf("%d delivery attempts on stop, want 1", attempts)
+	}
+
+	// the restarted runner delivers the pending events once drained
+	r = newDigestRunner(t, p, diskQueue)
+	waitFor(t, "the events to be recovered", func() bool { return r.acks.inflight() == 2 })
+	if !r.WaitDrained(2 * time.Second) {
+		t.Fatal("Runner was not drained")
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if digests := p.received(); len(digests) != 2 || len(digests[1]) != 2 {
+		t.Errorf("Digests = %v, want a second digest of 2 events", digests)
+	}
+}
+
+func TestDigestCollectsMoreEventsThanDiskQueueLimit(t *testing.T) {
+	dir := t.TempDir()
+	p := &digestPlugin{}
+	r := New("digest", p, func() (Queue, error) {
+		return NewDiskQueue(dir, 5, SyncNever)
+	}, map[events.EventKind]bool{events.ContainerEvent: true})
+	r.DigestInterval = time.Hour
+	r.DigestMaxEvents = 20
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer r.Stop()
+
+	for i := 0; i < 20; i++ {
+		r.Write(queueEvent(i))
+		if i < 19 {
+			waitFor(t, "the event to be collected", func() bool { return r.acks.inflight() == i+1 })
+		}
+	}
+	waitFor(t, "the digest", func() bool { return len(p.received()) == 1 })
+
+	if drops := r.eventQueue.Drops(); drops != 0 {
+		t.Errorf("Drops = %d, want 0", drops)
+	}
+	if digest := p.received()[0]; len(digest) != 20 {
+		t.Errorf("Digest of %d events, want 20", len(digest))
+	}
+}
@@ -0,0 +1,669 @@
+package pluginrunner
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SyncPolicy determines when writes to the disk queue are flushed to stable storage.
+type SyncPolicy string
+
+const (
+	// SyncAlways flushes every event to disk before Add returns.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval flushes pending writes once per second.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+const (
+	segmentExt          = ".seg"
+	cursorFileName      = "cursor"
+	lockFileName        = "lock"
+	recordHeaderSize    = 8
+	maxRecordSize       = 16 << 20
+	defaultSegmentSize  = 4 << 20
+	defaultSyncInterval = time.Second
+)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+type position struct {
+	seg uint64
+	off int64
+}
+
+// pendingRecord is a record that has been read from disk but
+// whose position has not been committed to the cursor file yet.
+type pendingRecord struct {
+	end  position
+	done bool
+}
+
+// DiskQueue is a persistent event queue backed by append-only segment
+// files. Events survive a restart until they have been acknowledged.
+// Segments that only contain acknowledged events are removed.
+// Unless the sync policy is SyncAlways, the acknowledged position is
+// persisted once per second, so events acknowledged shortly before a
+// crash may be delivered again.
+// If more than limit events wait to be delivered, events are dropped or
+// Add blocks according to the overflow policy. Delivered events that have
+// not been acknowledged yet, e.g. events collected for a digest, do not
+// count against the limit. The DropPriority policy is not supported.
+type DiskQueue struct {
+	Overflow     OverflowPolicy
+	BlockTimeout time.Duration
+
+	mu          sync.Mutex
+	dir         string
+	lock        *os.File // holds an exclusive lock on the directory
+	limit       int
+	policy      SyncPolicy
+	segmentSize int64
+
+	writer    *os.File
+	write     position
+	reader    *os.File
+	readerSeg uint64
+	read      position
+	ack       position
+	unread    int
+	delivered int
+	pending   []pendingRecord
+	drops     int
+	blocked   int
+	dirty     bool // writes have not been synced
+	ackDirty  bool // the acknowledged position has not been persisted
+
+	notify    chan struct{}
+	space     chan struct{}
+	out       chan events.Event
+	quit      chan struct{}
+	waitGroup sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewDiskQueue opens or creates a disk queue in the given directory.
+func NewDiskQueue(dir string, limit int, policy SyncPolicy) (*DiskQueue, error) {
+	if limit == 0 {
+		limit = DEFAULT_QUEUE_SIZE
+	}
+
+	switch policy {
+	case "":
+		policy = SyncInterval
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("Unknown queue sync policy '%s'", policy)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Could not create queue directory: %v", err)
+	}
+
+	q := &DiskQueue{
+		Overflow:     DropOldest,
+		BlockTimeout: DefaultBlockTimeout,
+		dir:          dir,
+		limit:        limit,
+		policy:       policy,
+		segmentSize:  defaultSegmentSize,
+		notify:       make(chan struct{}, 1),
+		space:        make(chan struct{}, 1),
+		out:          make(chan events.Event),
+		quit:         make(chan struct{}),
+	}
+
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	q.lock = lock
+
+	if err := q.recover(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"dir":     dir,
+		"pending": q.unread,
+	}).Debug("Opened disk queue")
+
+	q.waitGroup.Add(1)
+	go q.pump()
+
+	if policy != SyncAlways {
+		q.waitGroup.Add(1)
+		go q.syncLoop()
+	}
+
+	return q, nil
+}
+
+// Add appends an event to the queue.
+func (q *DiskQueue) Add(ev events.Event) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"eventId": ev.ID,
+			"error":   err,
+		}).Error("Could not encode event for disk queue")
+		q.mu.Lock()
+		q.drops++
+		q.mu.Unlock()
+		return
+	}
+
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch q.Overflow {
+	case DropNewest:
+		if q.full() {
+			q.drops++
+			return
+		}
+	case Block:
+		if q.full() {
+			q.blocked++
+			if !waitForSpace(&q.mu, q.space, q.BlockTimeout, q.full) {
+				q.drops++
+				return
+			}
+		}
+	}
+
+	if q.writer == nil {
+		log.WithField("eventId", ev.ID).Warn("Dropping event. Event queue is closed.")
+		q.drops++
+		return
+	}
+
+	if q.write.off >= q.segmentSize {
+		if err := q.rotate(); err != nil {
+			log.WithField("error", err).Error("Could not rotate disk queue segment")
+			q.drops++
+			return
+		}
+	}
+
+	if _, err := q.writer.Write(record); err != nil {
+		log.WithField("error", err).Error("Could not write event to disk queue")
+		q.drops++
+		return
+	}
+	q.write.off += int64(len(record))
+	q.unread++
+
+	if q.policy == SyncAlways {
+		if err := q.writer.Sync(); err != nil {
+			log.WithField("error", err).Error("Could not sync disk queue")
+		}
+	} else {
+		q.dirty = true
+	}
+
+	for q.unread > q.limit {
+		q.skip()
+	}
+
+	signal(q.notify)
+}
+
+func (q *DiskQueue) full() bool {
+	return q.unread >= q.limit
+}
+
+// Events returns the channel the queued events are delivered on.
+func (q *DiskQueue) Events() <-chan events.Event {
+	return q.out
+}
+
+// Done acknowledges the oldest delivered event and commits the
+// read position once all preceding events have been acknowledged.
+func (q *DiskQueue) Done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.pending {
+		if !q.pending[i].done {
+			q.pending[i].done = true
+			q.delivered--
+			break
+		}
+	}
+	q.commit()
+	signal(q.space)
+}
+
+// Size returns the number of events that have not been acknowledged.
+func (q *DiskQueue) Size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.unread + q.delivered
+}
+
+// Drops returns the total number of dropped events.
+func (q *DiskQueue) Drops() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.drops
+}
+
+// Blocked returns the total number of events that had to wait for room in the queue.
+func (q *DiskQueue) Blocked() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.blocked
+}
+
+// Close stops delivering events and flushes the queue to disk.
+// Calling Close more than once returns the result of the first call.
+func (q *DiskQueue) Close() error {
+	q.closeOnce.Do(func() {
+		q.closeErr = q.close()
+	})
+	return q.closeErr
+}
+
+func (q *DiskQueue) close() error {
+	close(q.quit)
+	q.waitGroup.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var err error
+	if q.writer != nil {
+		err = q.writer.Sync()
+		if cerr := q.writer.Close(); err == nil {
+			err = cerr
+		}
+		q.writer = nil
+	}
+	if q.reader != nil {
+		q.reader.Close()
+		q.reader = nil
+	}
+	if cerr := q.writeCursor(); err == nil {
+		err = cerr
+	}
+	q.lock.Close()
+	return err
+}
+
+// lockDir takes an exclusive lock on the queue directory, so that a queue
+// is not opened by two processes at once. The lock is released when the
+// returned file is closed, including when the process exits.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open queue lock file: %v", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("Queue directory %s is in use by another process", dir)
+		}
+		return nil, fmt.Errorf("Could not lock queue directory: %v", err)
+	}
+	return f, nil
+}
+
+func (q *DiskQueue) pump() {
+	defer q.waitGroup.Done()
+	for {
+		q.mu.Lock()
+		if q.unread == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.notify:
+				continue
+			case <-q.quit:
+				return
+			}
+		}
+
+		ev, err := q.advance()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"dir":   q.dir,
+				"error": err,
+			}).Error("Could not read from disk queue, dropping unread events")
+			q.drops += q.unread
+			q.unread = 0
+			q.read = q.write
+			q.pending = append(q.pending, pendingRecord{end: q.read, done: true})
+			q.commit()
+			q.mu.Unlock()
+			continue
+		}
+		q.pending = append(q.pending, pendingRecord{end: q.read})
+		q.unread--
+		q.delivered++
+		q.mu.Unlock()
+		signal(q.space)
+
+		select {
+		case q.out <- ev:
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+func (q *DiskQueue) syncLoop() {
+	defer q.waitGroup.Done()
+	ticker := time.NewTicker(defaultSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.quit:
+			return
+		case <-ticker.C:
+			q.mu.Lock()
+			if q.policy == SyncInterval && q.dirty && q.writer != nil {
+				if err := q.writer.Sync(); err != nil {
+					log.WithField("error", err).Error("Could not sync disk queue")
+				}
+				q.dirty = false
+			}
+			q.persistAck()
+			q.mu.Unlock()
+		}
+	}
+}
+
+// skip drops the oldest undelivered event.
+func (q *DiskQueue) skip() {
+	if _, err := q.advance(); err != nil {
+		q.read = q.write
+		q.drops += q.unread
+		q.unread = 0
+	} else {
+		q.unread--
+		q.drops++
+	}
+	q.pending = append(q.pending, pendingRecord{end: q.read, done: true})
+	q.commit()
+}
+
+// advance reads the record at the read position and moves the read
+// position past it. Unreadable segment tails are skipped.
+func (q *DiskQueue) advance() (events.Event, error) {
+	for {
+		if q.reader == nil || q.readerSeg != q.read.seg {
+			if q.reader != nil {
+				q.reader.Close()
+				q.reader = nil
+			}
+			f, err := os.Open(q.segmentPath(q.read.seg))
+			if err != nil && !os.IsNotExist(err) {
+				return events.Event{}, err
+			}
+			if err == nil {
+				q.reader = f
+				q.readerSeg = q.read.seg
+			}
+		}
+
+		if q.reader != nil {
+			ev, n, err := readRecord(q.reader, q.read.off)
+			if err == nil {
+				q.read.off += n
+				return ev, nil
+			}
+			if q.read.seg >= q.write.seg {
+				return events.Event{}, err
+			}
+		} else if q.read.seg >= q.write.seg {
+			return events.Event{}, fmt.Errorf("Missing segment %d", q.read.seg)
+		}
+
+		q.read = position{seg: q.read.seg + 1}
+	}
+}
+
+// commit advances the acknowledged position over all leading done
+// records. The position is persisted right away with SyncAlways and
+// by the sync loop otherwise.
+func (q *DiskQueue) commit() {
+	n := 0
+	for n < len(q.pending) && q.pending[n].done {
+		q.ack = q.pending[n].end
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	q.pending = q.pending[n:]
+	q.ackDirty = true
+
+	if q.policy == SyncAlways {
+		q.persistAck()
+	}
+}
+
+// persistAck writes the acknowledged position to the cursor file
+// and removes fully consumed segments.
+func (q *DiskQueue) persistAck() {
+	if !q.ackDirty {
+		return
+	}
+	if err := q.writeCursor(); err != nil {
+		log.WithField("error", err).Error("Could not write disk queue cursor")
+		return
+	}
+	q.ackDirty = false
+	q.compact()
+}
+
+func (q *DiskQueue) compact() {
+	segments, err := q.segments()
+	if err != nil {
+		return
+	}
+	for _, seg := range segments {
+		if seg >= q.ack.seg || seg >= q.write.seg {
+			break
+		}
+		if err := os.Remove(q.segmentPath(seg)); err != nil {
+			log.WithField("error", err).Warn("Could not remove disk queue segment")
+		}
+	}
+}
+
+func (q *DiskQueue) rotate() error {
+	if err := q.writer.Sync(); err != nil {
+		return err
+	}
+	if err := q.writer.Close(); err != nil {
+		return err
+	}
+	q.dirty = false
+
+	next := position{seg: q.write.seg + 1}
+	f, err := os.OpenFile(q.segmentPath(next.seg), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		q.writer = nil
+		return err
+	}
+	q.writer = f
+	q.write = next
+	return nil
+}
+
+// recover restores the queue state from the segment and cursor files.
+// A partially written record at the end of the last segment is truncated.
+func (q *DiskQueue) recover() error {
+	segments, err := q.segments()
+	if err != nil {
+		return fmt.Errorf("Could not list queue segments: %v", err)
+	}
+	if len(segments) == 0 {
+		segments = []uint64{1}
+	}
+	first, last := segments[0], segments[len(segments)-1]
+
+	q.ack = q.readCursor()
+	if q.ack.seg < first || q.ack.seg > last {
+		q.ack = position{seg: first}
+	}
+	q.read = q.ack
+	q.write = position{seg: last}
+
+	for _, seg := range segments {
+		if seg < q.ack.seg {
+			continue
+		}
+		off := int64(0)
+		if seg == q.ack.seg {
+			off = q.ack.off
+		}
+		f, err := os.OpenFile(q.segmentPath(seg), os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("Could not open queue segment: %v", err)
+		}
+		for {
+			_, n, err := readRecord(f, off)
+			if err != nil {
+				break
+			}
+			off += n
+			q.unread++
+		}
+		if seg == last {
+			if err := f.Truncate(off); err != nil {
+				f.Close()
+				return fmt.Errorf("Could not truncate queue segment: %v", err)
+			}
+			q.write.off = off
+		}
+		f.Close()
+	}
+
+	q.writer, err = os.OpenFile(q.segmentPath(last), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Could not open queue segment: %v", err)
+	}
+
+	q.compact()
+	return nil
+}
+
+func (q *DiskQueue) segments() ([]uint64, error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []uint64
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), segmentExt) {
+			continue
+		}
+		seg, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (q *DiskQueue) segmentPath(seg uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seg, segmentExt))
+}
+
+func (q *DiskQueue) readCursor() position {
+	var pos position
+	data, err := ioutil.ReadFile(filepath.Join(q.dir, cursorFileName))
+	if err != nil {
+		return pos
+	}
+	if _, err := fmt.Sscanf(string(data), "%d %d", &pos.seg, &pos.off); err != nil {
+		return position{}
+	}
+	return pos
+}
+
+// writeCursor atomically replaces the cursor file with the acknowledged position.
+func (q *DiskQueue) writeCursor() error {
+	path := filepath.Join(q.dir, cursorFileName)
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d %d\n", q.ack.seg, q.ack.off); err != nil {
+		f.Close()
+		return err
+	}
+	if q.policy == SyncAlways {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// readRecord decodes the record at the given offset and returns
+// the event and the total length of the record.
+func readRecord(f *os.File, off int64) (events.Event, int64, error) {
+	var ev events.Event
+	var header [recordHeaderSize]byte
+	if _, err := f.ReadAt(header[:], off); err != nil {
+		return ev, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxRecordSize {
+		return ev, 0, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := f.ReadAt(payload, off+recordHeaderSize); err != nil {
+		if err == io.EOF {
+			return ev, 0, errCorruptRecord
+		}
+		return ev, 0, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return ev, 0, errCorruptRecord
+	}
+
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return ev, 0, errCorruptRecord
+	}
+
+	return ev, recordHeaderSize + int64(length), nil
+}
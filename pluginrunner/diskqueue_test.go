@@ -0,0 +1,207 @@
+package pluginrunner
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+func openDiskQueue(t *testing.T, dir string, limit int, policy SyncPolicy) *DiskQueue {
+	q, err := NewDiskQueue(dir, limit, policy)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	return q
+}
+
+func queueEvent(i int) events.Event {
+	ev := events.Event{ID: fmt.Sprintf("ev-%d", i), Kind: events.ContainerEvent}
+	ev.ContainerData.ID = fmt.Sprintf("1i%d", i)
+	return ev
+}
+
+// receive reads the next event from the queue and acknowledges it if ack is set.
+func receive(t *testing.T, q *DiskQueue, ack bool) events.Event {
+	select {
+	case ev := <-q.Events():
+		if ack {
+			q.Done()
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	return events.Event{}
+}
+
+func expectNoEvent(t *testing.T, q *DiskQueue) {
+	select {
+	case ev := <-q.Events():
+		t.Fatalf("Unexpected event %s", ev.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDiskQueueRecoversUnacknowledgedEvents(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		t.Run(string(policy), func(t *testing.T) {
+			dir := t.TempDir()
+			q := openDiskQueue(t, dir, 10, policy)
+			for i := 0; i < 5; i++ {
+				q.Add(queueEvent(i))
+			}
+			receive(t, q, true)
+			receive(t, q, true)
+			// delivered, but not acknowledged
+			receive(t, q, false)
+			if err := q.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			q = openDiskQueue(t, dir, 10, policy)
+			defer q.Close()
+			if size := q.Size(); size != 3 {
+				t.Errorf("Size after recovery = %d, want 3", size)
+			}
+			for i := 2; i < 5; i++ {
+				if ev := receive(t, q, true); ev.ID != queueEvent(i).ID {
+					t.Errorf("Recovered event %s, want %s", ev.ID, queueEvent(i).ID)
+				}
+			}
+			expectNoEvent(t, q)
+		})
+	}
+}
+
+func TestDiskQueueTruncatesPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 10, SyncAlways)
+	q.Add(queueEvent(0))
+	q.Add(queueEvent(1))
+	segment := q.segmentPath(q.write.seg)
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// simulate a crash while writing a record
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 0, 1, 0, 0xde, 0xad, 0xbe, 0xef, '{'}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	q = openDiskQueue(t, dir, 10, SyncAlways)
+	if size := q.Size(); size != 2 {
+		t.Errorf("Size after recovery = %d, want 2", size)
+	}
+	q.Add(queueEvent(2))
+	for i := 0; i < 3; i++ {
+		if ev := receive(t, q, true); ev.ID != queueEvent(i).ID {
+			t.Errorf("Event %s, want %s", ev.ID, queueEvent(i).ID)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	q = openDiskQueue(t, dir, 10, SyncAlways)
+	defer q.Close()
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size after acknowledging all events = %d, want 0", size)
+	}
+	expectNoEvent(t, q)
+}
+
+func TestDiskQueueCompactsConsumedSegments(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 100, SyncAlways)
+	q.segmentSize = 1
+
+	for i := 0; i < 5; i++ {
+		q.Add(queueEvent(i))
+	}
+	segments, err := q.segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 5 {
+		t.Fatalf("%d segments, want one per event", len(segments))
+	}
+
+	for i := 0; i < 3; i++ {
+		receive(t, q, true)
+	}
+	segments, err = q.segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 3 {
+		t.Errorf("%d segments after acknowledging 3 of 5 events, want 3", len(segments))
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	q = openDiskQueue(t, dir, 100, SyncAlways)
+	defer q.Close()
+	if size := q.Size(); size != 2 {
+		t.Errorf("Size after recovery = %d, want 2", size)
+	}
+	for i := 3; i < 5; i++ {
+		if ev := receive(t, q, true); ev.ID != queueEvent(i).ID {
+			t.Errorf("Event %s, want %s", ev.ID, queueEvent(i).ID)
+		}
+	}
+}
+
+func TestDiskQueueDropsOldestWhenFull(t *testing.T) {
+	q := openDiskQueue(t, t.TempDir(), 2, SyncNever)
+	defer q.Close()
+
+	// the pump holds the first event until it is received,
+	// delivered events do not count against the limit
+	q.Add(queueEvent(0))
+	time.Sleep(50 * time.Millisecond)
+	for i := 1; i < 4; i++ {
+		q.Add(queueEvent(i))
+	}
+	if drops := q.Drops(); drops != 1 {
+		t.Errorf("Drops = %d, want 1", drops)
+	}
+	for _, i := range []int{0, 2, 3} {
+		if ev := receive(t, q, true); ev.ID != queueEvent(i).ID {
+			t.Errorf("Event %s, want %s", ev.ID, queueEvent(i).ID)
+		}
+	}
+}
+
+func TestDiskQueueCloseTwice(t *testing.T) {
+	q := openDiskQueue(t, t.TempDir(), 10, SyncInterval)
+	q.Add(queueEvent(0))
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Second Close: %v", err)
+	}
+}
+
+func TestDiskQueueLocksDirectory(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 10, SyncNever)
+	if _, err := NewDiskQueue(dir, 10, SyncNever); err == nil {
+		t.Fatal("Opened a queue directory that is in use")
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	q = openDiskQueue(t, dir, 10, SyncNever)
+	q.Close()
+}
@@ -0,0 +1,94 @@
+package pluginrunner
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+// Capacity of the channel of each worker.
+const workerBufferSize = 16
+
+// delivery is an event routed to a worker. Done must be called
+// once the event has been processed.
+type delivery struct {
+	ev   events.Event
+	done func()
+}
+
+// acker acknowledges events to the queue in the order they were received,
+// regardless of the order in which the workers finish processing them.
+type acker struct {
+	sync.Mutex
+	next   uint64 // sequence number of the next event
+	oldest uint64 // sequence number of the oldest unacknowledged event
+	done   map[uint64]bool
+}
+
+func newAcker() *acker {
+	return &acker{done: make(map[uint64]bool)}
+}
+
+func (a *acker) add() uint64 {
+	a.Lock()
+	defer a.Unlock()
+	seq := a.next
+	a.next++
+	return seq
+}
+
+// ack marks the event as processed and acknowledges all
+// events received before it that have been processed.
+func (a *acker) ack(seq uint64, queue Queue) {
+	a.Lock()
+	defer a.Unlock()
+	a.done[seq] = true
+	for a.done[a.oldest] {
+		delete(a.done, a.oldest)
+		a.oldest++
+		queue.Done()
+	}
+}
+
+// inflight returns the number of events taken from the queue
+// that have not been acknowledged yet.
+func (a *acker) inflight() int {
+	a.Lock()
+	defer a.Unlock()
+	return int(a.next - a.oldest)
+}
+
+// dispatch routes the events from the queue to the workers by resource,
+// so that the events of a resource are processed in order by the same
+// worker while unrelated resources are processed in parallel.
+func (r *PluginRunner) dispatch(workers []chan delivery) {
+	defer r.waitGroup.Done()
+	for {
+		select {
+		case <-r.quitChan:
+			return
+		case ev := <-r.eventQueue.Events():
+			seq := r.acks.add()
+			d := delivery{
+				ev:   ev,
+				done: func() { r.acks.ack(seq, r.eventQueue) },
+			}
+			select {
+			case workers[shard(ev, len(workers))] <- d:
+			case <-r.quitChan:
+				return
+			}
+		}
+	}
+}
+
+// shard returns the index of the worker handling the event's resource.
+func shard(ev events.Event, n int) int {
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(string(ev.Kind) + "/" + ev.GetResourceID()))
+	return int(h.Sum32() % uint32(n))
+}
@@ -1,7 +1,12 @@
 package pluginrunner
 
 import (
+	"sync"
+	"time"
+
 	"github.com/janeczku/eventbridge/events"
+
+	log "github.com/Sirupsen/logrus"
 )
 
 const (
@@ -9,38 +14,177 @@ const (
 	DEFAULT_QUEUE_SIZE = 50
 )
 
-// EventQueue is used for queuing events in a ring buffer.
-// If the buffer is full, then the oldest events are dropped
-// first.
+// EventQueue is an in-memory event queue. If the queue is full, events
+// are dropped or Add blocks according to the overflow policy.
 type EventQueue struct {
-	Buffer chan events.Event
-	drops  int
+	Overflow     OverflowPolicy
+	BlockTimeout time.Duration
+
+	mu        sync.Mutex // guards buffer and counters
+	buffer    []events.Event
+	size      int
+	delivered int // events taken from the buffer that have not been acknowledged
+	drops     int
+	blocked   int
+	closed    bool
+	notify    chan struct{}
+	space     chan struct{}
+	out       chan events.Event
+	quit      chan struct{}
+	closeOnce sync.Once
+	waitGroup sync.WaitGroup
 }
 
-// NewEventBuffer returns a new EventBuffer with the given capacity.
+// NewEventQueue returns a new EventQueue with the given capacity
+// that drops the oldest events first.
 func NewEventQueue(size int) *EventQueue {
-	return &EventQueue{
-		Buffer: make(chan events.Event, size),
+	if size == 0 {
+		size = DEFAULT_QUEUE_SIZE
+	}
+	eq := &EventQueue{
+		Overflow:     DropOldest,
+		BlockTimeout: DefaultBlockTimeout,
+		size:         size,
+		notify:       make(chan struct{}, 1),
+		space:        make(chan struct{}, 1),
+		out:          make(chan events.Event),
+		quit:         make(chan struct{}),
+	}
+	eq.waitGroup.Add(1)
+	go eq.pump()
+	return eq
+}
+
+// Events returns the channel the queued events are delivered on.
+func (eq *EventQueue) Events() <-chan events.Event {
+	return eq.out
+}
+
+// Done acknowledges an event received from the Events channel.
+func (eq *EventQueue) Done() {
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+	if eq.delivered > 0 {
+		eq.delivered--
 	}
 }
 
-// Size returns the current size of the queue.
+// Close stops delivering events. Queued events are discarded.
+// Calling Close more than once has no effect.
+func (eq *EventQueue) Close() error {
+	eq.closeOnce.Do(func() {
+		eq.mu.Lock()
+		eq.closed = true
+		eq.mu.Unlock()
+		signal(eq.space)
+		close(eq.quit)
+		eq.waitGroup.Wait()
+	})
+	return nil
+}
+
+// Size returns the number of queued events, including the events
+// taken from the queue that have not been acknowledged yet.
 func (eq *EventQueue) Size() int {
-	return len(eq.Buffer)
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+	return len(eq.buffer) + eq.delivered
 }
 
 // Drops returns the total number of dropped events.
 func (eq *EventQueue) Drops() int {
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
 	return eq.drops
 }
 
-// Add adds an event to the queue.
+// Blocked returns the total number of events that had to wait for room in the queue.
+func (eq *EventQueue) Blocked() int {
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+	return eq.blocked
+}
+
+// Add adds an event to the queue. Events added after Close are dropped.
 func (eq *EventQueue) Add(event events.Event) {
-	select {
-	case eq.Buffer <- event:
-	default:
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+
+	if eq.full() && !eq.makeRoom(event) {
 		eq.drops++
-		<-eq.Buffer
-		eq.Buffer <- event
+		return
+	}
+	if eq.closed {
+		log.WithField("eventId", event.ID).Warn("Dropping event. Event queue is closed.")
+		eq.drops++
+		return
+	}
+	eq.buffer = append(eq.buffer, event)
+	signal(eq.notify)
+}
+
+// full reports false once the queue is closed, so a blocked Add returns.
+func (eq *EventQueue) full() bool {
+	return !eq.closed && len(eq.buffer) >= eq.size
+}
+
+// makeRoom applies the overflow policy to the full queue. It returns
+// false if the event being added must be dropped instead.
+func (eq *EventQueue) makeRoom(event events.Event) bool {
+	switch eq.Overflow {
+	case DropNewest:
+		return false
+	case Block:
+		eq.blocked++
+		return waitForSpace(&eq.mu, eq.space, eq.BlockTimeout, eq.full)
+	case DropPriority:
+		for i, ev := range eq.buffer {
+			if !important(ev) {
+				eq.remove(i)
+				return true
+			}
+		}
+		if !important(event) {
+			return false
+		}
+	}
+	eq.remove(0)
+	return true
+}
+
+// remove drops the queued event at index i.
+func (eq *EventQueue) remove(i int) {
+	copy(eq.buffer[i:], eq.buffer[i+1:])
+	eq.buffer[len(eq.buffer)-1] = events.Event{}
+	eq.buffer = eq.buffer[:len(eq.buffer)-1]
+	eq.drops++
+}
+
+func (eq *EventQueue) pump() {
+	defer eq.waitGroup.Done()
+	for {
+		eq.mu.Lock()
+		if len(eq.buffer) == 0 {
+			eq.mu.Unlock()
+			select {
+			case <-eq.notify:
+				continue
+			case <-eq.quit:
+				return
+			}
+		}
+		ev := eq.buffer[0]
+		eq.buffer[0] = events.Event{}
+		eq.buffer = eq.buffer[1:]
+		// counted until acknowledged, so the queue is not drained meanwhile
+		eq.delivered++
+		eq.mu.Unlock()
+		signal(eq.space)
+
+		select {
+		case eq.out <- ev:
+		case <-eq.quit:
+			return
+		}
 	}
 }
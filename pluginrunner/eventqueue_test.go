@@ -0,0 +1,82 @@
+package pluginrunner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventQueueCountsUnacknowledgedEvents(t *testing.T) {
+	q := NewEventQueue(10)
+	defer q.Close()
+
+	q.Add(queueEvent(0))
+	q.Add(queueEvent(1))
+	// the pump has taken the first event, but it has not been received yet
+	time.Sleep(50 * time.Millisecond)
+	if size := q.Size(); size != 2 {
+		t.Errorf("Size = %d, want 2", size)
+	}
+
+	select {
+	case <-q.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	if size := q.Size(); size != 2 {
+		t.Errorf("Size before acknowledging = %d, want 2", size)
+	}
+	q.Done()
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size after acknowledging = %d, want 1", size)
+	}
+}
+
+func TestEventQueueCloseTwice(t *testing.T) {
+	q := NewEventQueue(10)
+	q.Add(queueEvent(0))
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Second Close: %v", err)
+	}
+}
+
+func TestEventQueueDropsEventsAddedAfterClose(t *testing.T) {
+	q := NewEventQueue(10)
+	q.Close()
+
+	q.Add(queueEvent(0))
+	if drops := q.Drops(); drops != 1 {
+		t.Errorf("Drops = %d, want 1", drops)
+	}
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size = %d, want 0", size)
+	}
+}
+
+func TestEventQueueCloseReleasesBlockedAdd(t *testing.T) {
+	q := NewEventQueue(1)
+	q.Overflow = Block
+	q.BlockTimeout = time.Minute
+	// the pump holds the first event until it is received
+	q.Add(queueEvent(0))
+	q.Add(queueEvent(1))
+
+	added := make(chan struct{})
+	go func() {
+		q.Add(queueEvent(2))
+		close(added)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	q.Close()
+
+	select {
+	case <-added:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Add is still blocked after Close")
+	}
+	if drops := q.Drops(); drops != 1 {
+		t.Errorf("Drops = %d, want 1", drops)
+	}
+}
@@ -0,0 +1,40 @@
+package pluginrunner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestErrorRateDecays(t *testing.T) {
+	m := &PluginMetrics{}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		m.recordAt(true, start)
+	}
+	m.recordAt(false, start.Add(time.Second))
+
+	if rate := m.errorRateAt(start.Add(time.Second)); rate != 0.75 {
+		t.Errorf("error rate = %v, want 0.75", rate)
+	}
+	if rate := m.errorRateAt(start.Add(errorRateWindow / 2)); rate != 0.75 {
+		t.Errorf("error rate within the window = %v, want 0.75", rate)
+	}
+	if rate := m.errorRateAt(start.Add(errorRateWindow + bucketWidth)); rate != 0 {
+		t.Errorf("error rate of an idle plugin = %v, want 0", rate)
+	}
+	if m.Errors != 3 || m.Successes != 1 {
+		t.Errorf("errors = %d, successes = %d", m.Errors, m.Successes)
+	}
+}
+
+func TestErrorRateReusesBuckets(t *testing.T) {
+	m := &PluginMetrics{}
+	start := time.Now()
+	m.recordAt(true, start)
+	later := start.Add(errorRateWindow + bucketWidth)
+	m.recordAt(false, later)
+
+	if rate := m.errorRateAt(later); rate != 0 {
+		t.Errorf("error rate = %v, want 0", rate)
+	}
+}
@@ -0,0 +1,78 @@
+package pluginrunner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+// OverflowPolicy determines what happens when an event is added to a full queue.
+type OverflowPolicy string
+
+const (
+	// DropOldest drops the oldest queued event.
+	DropOldest OverflowPolicy = "drop-oldest"
+	// DropNewest drops the event being added.
+	DropNewest OverflowPolicy = "drop-newest"
+	// Block waits for the queue to make room, applying backpressure to the
+	// event receiver, which delays the events of the other plugins as well.
+	// The event is dropped if the block timeout expires.
+	Block OverflowPolicy = "block"
+	// DropPriority drops the oldest routine event, keeping events with the
+	// warning or critical severity. If only those are queued, a routine event being
+	// added is dropped, otherwise the oldest queued event.
+	DropPriority OverflowPolicy = "priority"
+)
+
+// DefaultBlockTimeout is the maximum time Add waits for room with the Block policy.
+const DefaultBlockTimeout = 5 * time.Second
+
+// ParseOverflowPolicy validates the name of an overflow policy.
+// An empty name selects DropOldest.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(name); policy {
+	case "":
+		return DropOldest, nil
+	case DropOldest, DropNewest, Block, DropPriority:
+		return policy, nil
+	}
+	return "", fmt.Errorf("Unknown queue overflow policy '%s'", name)
+}
+
+// important returns true for events that are kept over routine events
+// by the DropPriority policy.
+func important(ev events.Event) bool {
+	if ev.Severity == "" {
+		return events.Classify(ev).Problem()
+	}
+	return ev.Severity.Problem()
+}
+
+// waitForSpace waits until full returns false or the timeout expires and
+// returns false in the latter case. The lock must be held when calling it;
+// it is released while waiting. space is signalled whenever room is made.
+func waitForSpace(mu *sync.Mutex, space <-chan struct{}, timeout time.Duration, full func() bool) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for full() {
+		mu.Unlock()
+		select {
+		case <-space:
+			mu.Lock()
+		case <-timer.C:
+			mu.Lock()
+			return !full()
+		}
+	}
+	return true
+}
+
+// signal performs a non-blocking send on a channel with a buffer of one.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
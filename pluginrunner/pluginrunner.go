@@ -1,9 +1,13 @@
 package pluginrunner
 
 import (
+	"io"
 	"sync"
+	"time"
 
 	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/filter"
+	"github.com/janeczku/eventbridge/metrics"
 	"github.com/janeczku/eventbridge/plugins"
 
 	log "github.com/Sirupsen/logrus"
@@ -11,73 +15,233 @@ import (
 
 // PluginMetrics tracks various metrics for the plugin runner.
 type PluginMetrics struct {
-	sync.Mutex     // not used ATM
-	Pending    int // events currently queued
-	Dropped    int // total events dropped from queue
-	Totals     int // total events received
-	Successes  int // total succesfull event writes
-	Errors     int // total errored event writes
+	sync.Mutex
+	Pending      int                // events currently queued
+	Dropped      int                // total events dropped from queue
+	Blocked      int                // total events that waited for room in the queue
+	Totals       int                // total events received
+	Successes    int                // total succesfull event writes
+	Errors       int                // total errored event writes
+	Retries      int                // total retried event writes
+	DeadLettered int                // total events passed to the dead letter sink
+	Latency      *metrics.Histogram // duration of event writes in seconds
+	ErrorRate    float64            // ratio of failed writes within the error rate window
+
+	outcomes [errorRateBuckets]outcomeBucket // results of the recent writes
+}
+
+// outcomeBucket counts the event writes within a slot of bucketWidth.
+type outcomeBucket struct {
+	slot          int64
+	total, failed int
+}
+
+// The error rate is computed over the event writes of the last errorRateWindow,
+// counted in buckets, so that it decays once a plugin becomes idle.
+const (
+	errorRateWindow  = 5 * time.Minute
+	errorRateBuckets = 30
+	bucketWidth      = errorRateWindow / errorRateBuckets
+)
+
+// inc increments the given counter of the metrics object.
+func (m *PluginMetrics) inc(counter *int) {
+	m.Lock()
+	*counter++
+	m.Unlock()
+}
+
+// record adds the result of an event write to the recent outcomes.
+func (m *PluginMetrics) record(failed bool) {
+	m.recordAt(failed, time.Now())
+}
+
+func (m *PluginMetrics) recordAt(failed bool, now time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	if failed {
+		m.Errors++
+	} else {
+		m.Successes++
+	}
+	slot := now.UnixNano() / int64(bucketWidth)
+	b := &m.outcomes[slot%errorRateBuckets]
+	if b.slot != slot {
+		*b = outcomeBucket{slot: slot}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+}
+
+// errorRate returns the ratio of failed writes within the error rate window.
+func (m *PluginMetrics) errorRate() float64 {
+	return m.errorRateAt(time.Now())
+}
+
+func (m *PluginMetrics) errorRateAt(now time.Time) float64 {
+	oldest := now.UnixNano()/int64(bucketWidth) - errorRateBuckets
+	total, failed := 0, 0
+	for _, b := range m.outcomes {
+		if b.slot > oldest {
+			total += b.total
+			failed += b.failed
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(failed) / float64(total)
 }
 
 // PluginRunner wraps a single plugin and queues events in a buffer.
 type PluginRunner struct {
-	Name        string
+	Name        string // instance name of the plugin
+	Type        string // name the plugin is registered with
 	Plugin      plugins.Plugin
 	EventKinds  map[events.EventKind]bool
+	Filter      *filter.Filter
+	Retry       RetryPolicy
+	DeadLetter  DeadLetterSink
 	WorkerCount int
 	Metrics     *PluginMetrics
+	QueueDir    string // directory of the disk queue, empty for memory queues
+
+	// Batches passed to plugins implementing plugins.BatchProcessor.
+	// Batching is disabled if BatchSize is less than 2.
+	BatchSize    int
+	BatchTimeout time.Duration
 
-	eventQueue *EventQueue
+	// Digests passed to plugins implementing plugins.DigestProcessor every
+	// DigestInterval or once DigestMaxEvents events are collected. The digest
+	// mode is enabled if either is positive and uses a single worker.
+	DigestInterval  time.Duration
+	DigestMaxEvents int
+
+	newQueue   QueueFactory
+	eventQueue Queue
+	acks       *acker
 	quitChan   chan struct{}
+	flushChan  chan struct{}
 	waitGroup  *sync.WaitGroup
 }
 
-func New(name string, plugin plugins.Plugin, queueLimit int, kinds map[events.EventKind]bool) *PluginRunner {
-	if queueLimit == 0 {
-		queueLimit = DEFAULT_QUEUE_SIZE
-	}
+// DefaultBatchTimeout is the maximum time to wait for a batch to fill up.
+const DefaultBatchTimeout = time.Second
+
+// QueueFactory creates the event queue of a plugin runner when it is started.
+type QueueFactory func() (Queue, error)
+
+func New(name string, plugin plugins.Plugin, newQueue QueueFactory, kinds map[events.EventKind]bool) *PluginRunner {
 	r := &PluginRunner{
-		Name:        name,
-		Plugin:      plugin,
-		EventKinds:  kinds,
-		WorkerCount: 1,
-		Metrics:     new(PluginMetrics),
-		eventQueue:  NewEventQueue(queueLimit),
-		waitGroup:   &sync.WaitGroup{},
-		quitChan:    make(chan struct{}),
+		Name:         name,
+		Type:         name,
+		Plugin:       plugin,
+		EventKinds:   kinds,
+		Retry:        DefaultRetryPolicy(),
+		WorkerCount:  1,
+		Metrics:      &PluginMetrics{Latency: metrics.NewHistogram(metrics.DefaultBuckets)},
+		BatchTimeout: DefaultBatchTimeout,
+		newQueue:     newQueue,
+		acks:         newAcker(),
+		waitGroup:    &sync.WaitGroup{},
+		quitChan:     make(chan struct{}),
+		flushChan:    make(chan struct{}, 1),
 	}
 	return r
 }
 
+// Accepts returns true if the event is of a kind handled by the plugin
+// and matches the configured filter expression.
+func (r *PluginRunner) Accepts(ev events.Event) bool {
+	if _, ok := r.EventKinds[ev.Kind]; !ok {
+		return false
+	}
+	if r.Filter != nil && !r.Filter.Match(ev) {
+		return false
+	}
+	return true
+}
+
 // Write adds an event to the event queue.
 func (r *PluginRunner) Write(ev events.Event) {
 	log.WithFields(log.Fields{
 		"eventId": ev.ID,
 		"plugin":  r.Name,
 	}).Debug("Adding event to queue")
-	r.Metrics.Totals++
+	if r.eventQueue == nil {
+		log.WithFields(log.Fields{
+			"eventId": ev.ID,
+			"plugin":  r.Name,
+		}).Warn("Dropping event. Plugin runner is not started.")
+		return
+	}
+	r.Metrics.inc(&r.Metrics.Totals)
 	r.eventQueue.Add(ev)
 }
 
-// Start invokes the plugin's Init method and dispatches event queue routine.
+// Start opens the event queue, invokes the plugin's Init method and dispatches
+// the event queue routine and the workers. Events of the same resource are
+// always processed by the same worker.
 func (r *PluginRunner) Start() error {
+	queue, err := r.newQueue()
+	if err != nil {
+		return err
+	}
+	r.Metrics.Lock()
+	r.eventQueue = queue
+	r.Metrics.Unlock()
+
 	log.WithField("plugin", r.Name).Info("Initializing plugin")
 	if err := r.Plugin.Init(); err != nil {
+		queue.Close()
 		return err
 	}
 
-	r.waitGroup.Add(r.WorkerCount)
-	for i := 0; i < r.WorkerCount; i++ {
-		go r.doWork()
+	dp, digest := r.Plugin.(plugins.DigestProcessor)
+	digest = digest && r.digestMode()
+	if r.WorkerCount < 1 || digest {
+		r.WorkerCount = 1
+	}
+	bp, batch := r.Plugin.(plugins.BatchProcessor)
+	batch = batch && r.BatchSize > 1
+	workers := make([]chan delivery, r.WorkerCount)
+	r.waitGroup.Add(r.WorkerCount + 1)
+	for i := range workers {
+		workers[i] = make(chan delivery, workerBufferSize)
+		switch {
+		case digest:
+			go r.doDigestWork(dp, workers[i])
+		case batch:
+			go r.doBatchWork(bp, workers[i])
+		default:
+			go r.doWork(workers[i])
+		}
 	}
+	go r.dispatch(workers)
 
 	return nil
 }
 
-// Stop stops the event queue routine and invokes the plugin's Close method.
+// Stop stops the event queue routine, closes the queue and invokes the plugin's Close method.
 func (r *PluginRunner) Stop() error {
 	close(r.quitChan)
 	r.waitGroup.Wait()
+	if err := r.eventQueue.Close(); err != nil {
+		log.WithFields(log.Fields{
+			"error":  err,
+			"plugin": r.Name,
+		}).Error("Error closing event queue")
+	}
+	if closer, ok := r.DeadLetter.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.WithFields(log.Fields{
+				"error":  err,
+				"plugin": r.Name,
+			}).Error("Error closing dead letter sink")
+		}
+	}
 	log.WithField("plugin", r.Name).Info("Closing plugin")
 	if err := r.Plugin.Close(); err != nil {
 		return err
@@ -86,34 +250,144 @@ func (r *PluginRunner) Stop() error {
 	return nil
 }
 
-// Stats returns a populated metrics object.
+// Drain waits until all queued events have been processed or the
+// timeout expires and then stops the runner.
+func (r *PluginRunner) Drain(timeout time.Duration) error {
+	r.WaitDrained(timeout)
+	return r.Stop()
+}
+
+// WaitDrained waits until all queued events have been processed or the
+// timeout expires. It returns false in the latter case. Pending digests
+// are passed to the plugin right away.
+func (r *PluginRunner) WaitDrained(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !r.drained() && time.Now().Before(deadline) {
+		select {
+		case r.flushChan <- struct{}{}:
+		default:
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !r.drained() {
+		log.WithFields(log.Fields{
+			"pending":  r.eventQueue.Size(),
+			"inflight": r.acks.inflight(),
+			"plugin":   r.Name,
+		}).Warn("Plugin runner still has pending events")
+		return false
+	}
+	return true
+}
+
+// digestMode returns true if the digest mode is configured.
+func (r *PluginRunner) digestMode() bool {
+	return r.DigestInterval > 0 || r.DigestMaxEvents > 0
+}
+
+// drained returns true if all queued events have been processed.
+func (r *PluginRunner) drained() bool {
+	return r.eventQueue.Size() == 0 && r.acks.inflight() == 0
+}
+
+// Stats returns a populated copy of the metrics object.
 func (r *PluginRunner) Stats() *PluginMetrics {
-	r.Metrics.Pending = r.eventQueue.Size()
-	r.Metrics.Dropped = r.eventQueue.Drops()
-	return r.Metrics
+	r.Metrics.Lock()
+	defer r.Metrics.Unlock()
+	var pending, dropped, blocked int
+	if r.eventQueue != nil {
+		pending = r.eventQueue.Size()
+		dropped = r.eventQueue.Drops()
+		blocked = r.eventQueue.Blocked()
+	}
+	return &PluginMetrics{
+		Pending:      pending,
+		Dropped:      dropped,
+		Blocked:      blocked,
+		Totals:       r.Metrics.Totals,
+		Successes:    r.Metrics.Successes,
+		Errors:       r.Metrics.Errors,
+		Retries:      r.Metrics.Retries,
+		DeadLettered: r.Metrics.DeadLettered,
+		Latency:      r.Metrics.Latency,
+		ErrorRate:    r.Metrics.errorRate(),
+	}
 }
 
-func (r *PluginRunner) doWork() {
+// WriteDeadLetter adds an event that failed in another plugin
+// runner to the event queue.
+func (r *PluginRunner) WriteDeadLetter(plugin string, ev events.Event, err error) error {
+	r.Write(ev)
+	return nil
+}
+
+func (r *PluginRunner) doWork(deliveries <-chan delivery) {
 	log.WithField("plugin", r.Name).Debug("Plugin worker started")
 	defer r.waitGroup.Done()
 	for {
 		select {
 		case <-r.quitChan:
 			return
-		case ev := <-r.eventQueue.Buffer:
-			log.WithFields(log.Fields{
-				"eventId": ev.ID,
-				"plugin":  r.Name,
-			}).Debug("Writing event to plugin")
-			if err := r.Plugin.Process(ev); err != nil {
-				r.Metrics.Errors++
-				log.WithFields(log.Fields{
-					"error":  err,
-					"plugin": r.Name,
-				}).Error("Error writing event to plugin")
-			} else {
-				r.Metrics.Successes++
+		case d := <-deliveries:
+			if !r.process(d.ev) {
+				return
 			}
+			d.done()
+		}
+	}
+}
+
+// process passes the event to the plugin, retrying according to the retry
+// policy. Events that exhaust all attempts are passed to the dead letter sink.
+// It returns false if the runner was stopped before the event was processed.
+func (r *PluginRunner) process(ev events.Event) bool {
+	for attempt := 1; ; attempt++ {
+		log.WithFields(log.Fields{
+			"eventId": ev.ID,
+			"plugin":  r.Name,
+			"attempt": attempt,
+		}).Debug("Writing event to plugin")
+
+		start := time.Now()
+		err := r.Plugin.Process(ev)
+		r.Metrics.Latency.Observe(time.Since(start).Seconds())
+		if err == nil {
+			r.Metrics.record(false)
+			return true
+		}
+
+		r.Metrics.record(true)
+		log.WithFields(log.Fields{
+			"error":   err,
+			"plugin":  r.Name,
+			"attempt": attempt,
+		}).Error("Error writing event to plugin")
+
+		if attempt >= r.Retry.MaxAttempts || !plugins.IsRetryable(err) {
+			r.deadLetter(ev, err)
+			return true
+		}
+
+		r.Metrics.inc(&r.Metrics.Retries)
+		select {
+		case <-r.quitChan:
+			return false
+		case <-time.After(r.Retry.Backoff(attempt)):
 		}
 	}
 }
+
+func (r *PluginRunner) deadLetter(ev events.Event, err error) {
+	if r.DeadLetter == nil {
+		return
+	}
+	if derr := r.DeadLetter.WriteDeadLetter(r.Name, ev, err); derr != nil {
+		log.WithFields(log.Fields{
+			"error":   derr,
+			"eventId": ev.ID,
+			"plugin":  r.Name,
+		}).Error("Error writing event to dead letter sink")
+		return
+	}
+	r.Metrics.inc(&r.Metrics.DeadLettered)
+}
@@ -0,0 +1,25 @@
+package pluginrunner
+
+import (
+	"github.com/janeczku/eventbridge/events"
+)
+
+// Queue buffers the events of a plugin runner until they are processed.
+type Queue interface {
+	// Add adds an event to the queue.
+	Add(ev events.Event)
+	// Events returns the channel the queued events are delivered on.
+	Events() <-chan events.Event
+	// Done acknowledges the oldest event received from the Events
+	// channel that has not been acknowledged yet.
+	Done()
+	// Size returns the number of events that have not been processed yet.
+	Size() int
+	// Drops returns the total number of dropped events.
+	Drops() int
+	// Blocked returns the total number of events that had to wait
+	// for room in the queue.
+	Blocked() int
+	// Close releases any resources held by the queue.
+	Close() error
+}
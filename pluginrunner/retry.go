@@ -0,0 +1,35 @@
+package pluginrunner
+
+import (
+	"time"
+
+	"github.com/janeczku/eventbridge/backoff"
+)
+
+const (
+	DEFAULT_RETRY_INITIAL_BACKOFF = time.Second
+	DEFAULT_RETRY_MAX_BACKOFF     = time.Minute
+)
+
+// RetryPolicy controls how often and how fast failed events are
+// passed to the plugin again.
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts, including the first one
+	InitialBackoff time.Duration // delay before the first retry
+	MaxBackoff     time.Duration // upper bound of the delay
+}
+
+// DefaultRetryPolicy returns a policy that does not retry.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    1,
+		InitialBackoff: DEFAULT_RETRY_INITIAL_BACKOFF,
+		MaxBackoff:     DEFAULT_RETRY_MAX_BACKOFF,
+	}
+}
+
+// Backoff returns the delay before the given retry attempt (starting at 1),
+// see backoff.Exponential.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	return backoff.Exponential(attempt, p.InitialBackoff, p.MaxBackoff)
+}
@@ -0,0 +1,36 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+// BatchProcessor can be implemented by plugins that write events more
+// efficiently in bulk. If batching is configured for the plugin, the plugin
+// runner passes batches bounded in size and time instead of single events.
+type BatchProcessor interface {
+	// ProcessBatch accepts a batch of events for processing. If only some of
+	// the events failed, it returns a *BatchError identifying them.
+	ProcessBatch(evs []events.Event) error
+}
+
+// BatchError is returned by ProcessBatch if only some events of a batch failed.
+// The remaining events are considered processed.
+type BatchError struct {
+	Errors map[int]error // errors by index of the failed event in the batch
+}
+
+// Error reports the number of failed events and the error of the first one.
+func (e *BatchError) Error() string {
+	first := -1
+	for i := range e.Errors {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	if first < 0 {
+		return "No events of the batch failed"
+	}
+	return fmt.Sprintf("%d events of the batch failed, event %d: %v", len(e.Errors), first, e.Errors[first])
+}
@@ -0,0 +1,141 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+// DigestProcessor can be implemented by plugins that support the digest
+// mode. Instead of a stream of events the plugin periodically receives
+// a digest summarizing the events of the interval.
+type DigestProcessor interface {
+	// ProcessDigest accepts a digest of events for processing
+	ProcessDigest(digest *Digest) error
+}
+
+// Digest summarizes the events received over an interval.
+type Digest struct {
+	Start  time.Time
+	End    time.Time
+	Events []events.Event
+	Groups []*DigestGroup // sorted by stack and service
+}
+
+// DigestGroup holds the events of a digest that belong to the same
+// stack and service, counted by state and health state.
+type DigestGroup struct {
+	Stack   string
+	Service string
+	Events  []events.Event
+	States  map[events.InstanceState]int
+	Health  map[events.HealthState]int
+}
+
+// NewDigest groups the events by stack and service.
+func NewDigest(start, end time.Time, evs []events.Event) *Digest {
+	d := &Digest{
+		Start:  start,
+		End:    end,
+		Events: evs,
+	}
+	groups := make(map[[2]string]*DigestGroup)
+	for _, ev := range evs {
+		stack, service := ev.GetStackName(), ev.GetServiceName()
+		key := [2]string{stack, service}
+		g, ok := groups[key]
+		if !ok {
+			g = &DigestGroup{
+				Stack:   stack,
+				Service: service,
+				States:  make(map[events.InstanceState]int),
+				Health:  make(map[events.HealthState]int),
+			}
+			groups[key] = g
+			d.Groups = append(d.Groups, g)
+		}
+		g.Events = append(g.Events, ev)
+		g.States[ev.GetState()]++
+		g.Health[ev.GetHealthState()]++
+	}
+	sort.Sort(byStackService(d.Groups))
+	return d
+}
+
+type byStackService []*DigestGroup
+
+func (s byStackService) Len() int      { return len(s) }
+func (s byStackService) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byStackService) Less(i, j int) bool {
+	if s[i].Stack != s[j].Stack {
+		return s[i].Stack < s[j].Stack
+	}
+	return s[i].Service < s[j].Service
+}
+
+// Name returns "<stack>/<service>", omitting empty parts.
+func (g *DigestGroup) Name() string {
+	var parts []string
+	for _, p := range []string{g.Stack, g.Service} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "other"
+	}
+	return strings.Join(parts, "/")
+}
+
+// StateCounts returns the state counts formatted as "running: 3, stopped: 1".
+func (g *DigestGroup) StateCounts() string {
+	counts := make(map[string]int, len(g.States))
+	for state, n := range g.States {
+		counts[string(state)] = n
+	}
+	return formatCounts(counts)
+}
+
+// HealthCounts returns the health state counts formatted as "healthy: 3, unhealthy: 1".
+func (g *DigestGroup) HealthCounts() string {
+	counts := make(map[string]int, len(g.Health))
+	for health, n := range g.Health {
+		counts[string(health)] = n
+	}
+	return formatCounts(counts)
+}
+
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s: %d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Title returns a one line description of the digest.
+func (d *Digest) Title() string {
+	return fmt.Sprintf("Digest of %d events from %s to %s", len(d.Events),
+		d.Start.Format("2006-01-02 15:04:05"), d.End.Format("2006-01-02 15:04:05"))
+}
+
+// Text renders the digest as plain text, e.g. for the body of an email.
+func (d *Digest) Text() string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, d.Title())
+	for _, g := range d.Groups {
+		fmt.Fprintf(&buf, "\n%s (%d events)\n", g.Name(), len(g.Events))
+		fmt.Fprintf(&buf, "  states: %s\n", g.StateCounts())
+		fmt.Fprintf(&buf, "  health: %s\n", g.HealthCounts())
+	}
+	return buf.String()
+}
@@ -0,0 +1,34 @@
+package plugins
+
+// RetryableError can be implemented by errors returned from Process
+// to control whether delivery of the event is retried. Errors that do
+// not implement the interface are considered retryable.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string   { return e.err.Error() }
+func (e *permanentError) Retryable() bool { return false }
+
+// Permanent wraps an error to indicate that processing the event
+// will not succeed on a later attempt.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// IsRetryable reports whether processing of an event that failed
+// with the given error should be retried.
+func IsRetryable(err error) bool {
+	if r, ok := err.(RetryableError); ok {
+		return r.Retryable()
+	}
+	return true
+}
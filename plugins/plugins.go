@@ -15,3 +15,9 @@ type Plugin interface {
 	// Process accepts an event for processing
 	Process(ev events.Event) error
 }
+
+// InstanceNamer is implemented by plugins that want to know the name of
+// their plugin instance, e.g. "slack.ops", to use it in log messages.
+type InstanceNamer interface {
+	SetInstanceName(name string)
+}
@@ -0,0 +1,75 @@
+package slack
+
+import (
+	"strings"
+
+	"github.com/huguesalary/slack-go"
+)
+
+// Block Kit allows at most 10 fields per section
+const maxSectionFields = 10
+
+type block struct {
+	Type     string       `json:"type"`
+	Text     *textObject  `json:"text,omitempty"`
+	Fields   []textObject `json:"fields,omitempty"`
+	Elements []textObject `json:"elements,omitempty"`
+}
+
+type textObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func mrkdwn(text string) textObject {
+	return textObject{Type: "mrkdwn", Text: text}
+}
+
+// renderBlocks converts a rendered attachment to Block Kit blocks.
+// The status emoji, if any, is prepended to the pretext. Short fields
+// are laid out side by side, other fields get a section of their own.
+func renderBlocks(attach *slack.Attachment, emoji string) []block {
+	var head []string
+	if pretext := strings.TrimSpace(emoji + " " + bold(attach.Pretext)); pretext != "" {
+		head = append(head, pretext)
+	}
+	if attach.Text != "" {
+		head = append(head, attach.Text)
+	}
+
+	var blocks []block
+	if len(head) > 0 {
+		text := mrkdwn(strings.Join(head, "\n"))
+		blocks = append(blocks, block{Type: "section", Text: &text})
+	}
+
+	var short []textObject
+	flush := func() {
+		if len(short) > 0 {
+			blocks = append(blocks, block{Type: "section", Fields: short})
+			short = nil
+		}
+	}
+	for _, f := range attach.Fields {
+		field := mrkdwn(bold(f.Title) + "\n" + f.Value)
+		if !f.Short {
+			flush()
+			blocks = append(blocks, block{Type: "section", Text: &field})
+			continue
+		}
+		short = append(short, field)
+		if len(short) == maxSectionFields {
+			flush()
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+func bold(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "*" + text + "*"
+}
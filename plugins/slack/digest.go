@@ -0,0 +1,45 @@
+package slack
+
+import (
+	"fmt"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/plugins"
+
+	"github.com/huguesalary/slack-go"
+)
+
+// ProcessDigest posts a single message summarizing the digest, with a field
+// per stack and service. Events not matching the notification rules are
+// left out. Digests are not subject to the rate limit.
+func (s *Slack) ProcessDigest(digest *plugins.Digest) error {
+	var evs []events.Event
+	for _, ev := range digest.Events {
+		if s.notify(ev) {
+			evs = append(evs, ev)
+		}
+	}
+	if len(evs) == 0 {
+		return nil
+	}
+	digest = plugins.NewDigest(digest.Start, digest.End, evs)
+
+	msg := s.newMessage()
+	digestMessage(msg, digest, s.DefaultColor)
+	return s.send(msg)
+}
+
+func digestMessage(msg *slack.Message, digest *plugins.Digest, color string) {
+	attach := msg.NewAttachment()
+	attach.MarkdownIn = []string{"fields"}
+	attach.Color = color
+	attach.Pretext = digest.Title()
+	attach.Fallback = digest.Text()
+	for _, g := range digest.Groups {
+		attach.AddField(&slack.Field{
+			Title: fmt.Sprintf("%s (%d events)", g.Name(), len(g.Events)),
+			Value: fmt.Sprintf("State: %s\nHealth: %s", g.StateCounts(), g.HealthCounts()),
+			Short: true,
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/huguesalary/slack-go"
+	"github.com/juju/ratelimit"
+)
+
+const (
+	defaultRateLimit = 10 // messages per minute
+	defaultRateBurst = 10
+
+	// maximum number of suppressed events listed in a summary
+	maxSummarySamples = 10
+)
+
+// overflow accumulates the events suppressed by the rate limiter.
+type overflow struct {
+	sync.Mutex
+	count   int
+	samples []string
+}
+
+// admit takes a token from the bucket for the event. While suppressed events
+// are pending, the summary has priority over new events: they are suppressed
+// until the summary has been posted.
+func (o *overflow) admit(bucket *ratelimit.Bucket, ev events.Event) bool {
+	o.Lock()
+	defer o.Unlock()
+	if o.count == 0 && bucket.TakeAvailable(1) > 0 {
+		return true
+	}
+	o.count++
+	if len(o.samples) < maxSummarySamples {
+		o.samples = append(o.samples, fmt.Sprintf("%s `%s` is `%s` (health: `%s`)",
+			ev.Kind, ev.GetName(), ev.GetState(), ev.GetHealthState()))
+	}
+	return false
+}
+
+// take returns and resets the suppressed events if there are any and the
+// bucket has a token available. A nil bucket does not limit the summary.
+func (o *overflow) take(bucket *ratelimit.Bucket) (int, []string) {
+	o.Lock()
+	defer o.Unlock()
+	if o.count == 0 || (bucket != nil && bucket.TakeAvailable(1) == 0) {
+		return 0, nil
+	}
+	count, samples := o.count, o.samples
+	o.count, o.samples = 0, nil
+	return count, samples
+}
+
+// restore adds back the suppressed events of a summary that could not be posted.
+func (o *overflow) restore(count int, samples []string) {
+	o.Lock()
+	defer o.Unlock()
+	o.count += count
+	samples = append(samples, o.samples...)
+	if len(samples) > maxSummarySamples {
+		samples = samples[:maxSummarySamples]
+	}
+	o.samples = samples
+}
+
+// startRateLimiter creates the token bucket of the plugin instance
+// and dispatches the routine posting overflow summaries.
+func (s *Slack) startRateLimiter() {
+	if s.RateLimit <= 0 {
+		s.RateLimit = defaultRateLimit
+	}
+	if s.RateBurst <= 0 {
+		s.RateBurst = defaultRateBurst
+	}
+	fillInterval := time.Minute / time.Duration(s.RateLimit)
+	s.bucket = ratelimit.NewBucket(fillInterval, int64(s.RateBurst))
+	s.overflow = &overflow{}
+	s.quitChan = make(chan struct{})
+
+	s.waitGroup.Add(1)
+	go s.postSummaries(fillInterval)
+}
+
+// stopRateLimiter stops posting summaries and posts the pending one
+// regardless of the rate limit.
+func (s *Slack) stopRateLimiter() {
+	if s.quitChan == nil {
+		return
+	}
+	close(s.quitChan)
+	s.waitGroup.Wait()
+	s.quitChan = nil
+	s.postSummary(nil)
+}
+
+func (s *Slack) postSummaries(interval time.Duration) {
+	defer s.waitGroup.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.quitChan:
+			return
+		case <-ticker.C:
+			s.postSummary(s.bucket)
+		}
+	}
+}
+
+// postSummary posts the summary of the suppressed events if the bucket
+// allows. If posting fails, the events are kept for the next summary.
+func (s *Slack) postSummary(bucket *ratelimit.Bucket) {
+	count, samples := s.overflow.take(bucket)
+	if count == 0 {
+		return
+	}
+	if err := s.send(summaryMessage(s.newMessage(), count, samples)); err != nil {
+		log.WithFields(log.Fields{
+			"plugin": s.name,
+			"error":  err,
+			"events": count,
+		}).Error("Failed to post summary of suppressed events")
+		s.overflow.restore(count, samples)
+	}
+}
+
+func summaryMessage(msg *slack.Message, count int, samples []string) *slack.Message {
+	attach := msg.NewAttachment()
+	attach.MarkdownIn = []string{"text"}
+	attach.Color = defaultMsgColor
+	attach.Pretext = fmt.Sprintf("%d more events suppressed by the rate limit:", count)
+	text := strings.Join(samples, "\n")
+	if more := count - len(samples); more > 0 {
+		text += fmt.Sprintf("\n... and %d more", more)
+	}
+	attach.Text = text
+	attach.Fallback = attach.Pretext + "\n" + text
+	return msg
+}
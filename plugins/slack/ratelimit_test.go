@@ -0,0 +1,60 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/juju/ratelimit"
+)
+
+func rateLimitedEvent(i int) events.Event {
+	ev := events.Event{ID: fmt.Sprintf("%d", i), Kind: events.ContainerEvent}
+	ev.ContainerData.Name = fmt.Sprintf("web-%d", i)
+	return ev
+}
+
+func TestOverflowSummaryHasPriority(t *testing.T) {
+	bucket := ratelimit.NewBucket(50*time.Millisecond, 1)
+	o := &overflow{}
+
+	if !o.admit(bucket, rateLimitedEvent(0)) {
+		t.Fatal("First event was suppressed")
+	}
+	if o.admit(bucket, rateLimitedEvent(1)) {
+		t.Fatal("Event exceeding the limit was admitted")
+	}
+
+	// a token becomes available, but the pending summary comes first
+	time.Sleep(60 * time.Millisecond)
+	if o.admit(bucket, rateLimitedEvent(2)) {
+		t.Fatal("Event was admitted while a summary is pending")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if count, _ := o.take(bucket); count != 2 {
+		t.Fatalf("Summary of %d events, want 2", count)
+	}
+}
+
+func TestOverflowRestore(t *testing.T) {
+	o := &overflow{}
+	bucket := ratelimit.NewBucket(time.Hour, 1)
+	bucket.TakeAvailable(1)
+	for i := 0; i < maxSummarySamples; i++ {
+		o.admit(bucket, rateLimitedEvent(i))
+	}
+
+	count, samples := o.take(nil)
+	o.admit(bucket, rateLimitedEvent(maxSummarySamples))
+	o.restore(count, samples)
+
+	count, samples = o.take(nil)
+	if count != maxSummarySamples+1 {
+		t.Errorf("Summary of %d events after restore, want %d", count, maxSummarySamples+1)
+	}
+	if len(samples) != maxSummarySamples || !strings.Contains(samples[0], "`web-0`") {
+		t.Errorf("Samples after restore = %q", samples)
+	}
+}
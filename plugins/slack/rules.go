@@ -0,0 +1,35 @@
+package slack
+
+import (
+	"github.com/janeczku/eventbridge/events"
+)
+
+// Rule selects the events that trigger a notification.
+// Empty lists match any value.
+type Rule struct {
+	Kinds      []string
+	States     []string
+	Health     []string
+	Severities []string
+}
+
+// Match returns true if the event's kind, state, health state and severity
+// are all contained in the respective lists of the rule.
+func (r Rule) Match(ev events.Event) bool {
+	return matchAny(r.Kinds, string(ev.Kind)) &&
+		matchAny(r.States, string(ev.GetState())) &&
+		matchAny(r.Health, string(ev.GetHealthState())) &&
+		matchAny(r.Severities, string(ev.Severity))
+}
+
+func matchAny(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -3,7 +3,8 @@ package slack
 
 import (
 	"fmt"
-	"time"
+	"strings"
+	"sync"
 
 	"github.com/janeczku/eventbridge/events"
 	"github.com/janeczku/eventbridge/plugins"
@@ -19,27 +20,71 @@ const (
 
 var defaultMsgColor = "#CFCDC9"
 
-// throttle to 10 messages/min, burst 10
-var rateLimiter = ratelimit.NewBucket(5*time.Second, 10)
+var eventKinds = events.AllEventKinds
 
-var eventKinds = []events.EventKind{
-	events.ContainerEvent,
-	events.ServiceEvent,
+// default rule: resource states that trigger a Slack notification
+var defaultRules = []Rule{
+	{
+		Kinds: []string{
+			string(events.ContainerEvent),
+			string(events.ServiceEvent),
+		},
+		States: []string{
+			string(events.ServiceInactive),
+			string(events.ServiceActive),
+			string(events.ContainerStopped),
+			string(events.ContainerRunning),
+		},
+	},
 }
 
-// resource states that trigger a Slack notification and the color to use
-var states = map[events.InstanceState]string{
-	events.ServiceInactive:  "#CFCDC9",
-	events.ServiceActive:    "#99CC99",
-	events.ContainerStopped: "#CFCDC9",
-	events.ContainerRunning: "#99CC99",
+// default message color according to the severity, info events
+// are colored according to their state and health state
+var defaultSeverityColors = map[string]string{
+	string(events.SeverityCritical): "#F2777A",
+	string(events.SeverityWarning):  "#FFCC66",
+	string(events.SeverityResolved): "#99CC99",
 }
 
-// message color according to the health state
-var healthStates = map[events.HealthState]string{
-	events.StateHealthy:   "#99CC99",
-	events.StateUnhealthy: "#F2777A",
-	events.StateDegraded:  "#F2777A",
+// default message color according to the state
+var defaultStateColors = map[string]string{
+	string(events.ServiceInactive):  "#CFCDC9",
+	string(events.ServiceActive):    "#99CC99",
+	string(events.ContainerStopped): "#CFCDC9",
+	string(events.ContainerRunning): "#99CC99",
+}
+
+// default message color according to the health state
+var defaultHealthColors = map[string]string{
+	string(events.StateHealthy):   "#99CC99",
+	string(events.StateUnhealthy): "#F2777A",
+	string(events.StateDegraded):  "#F2777A",
+}
+
+// states in which the health state determines the message color
+var upStates = map[events.InstanceState]bool{
+	events.ServiceActive:         true,
+	events.ServiceUpdatingActive: true,
+	events.ContainerRunning:      true,
+}
+
+const (
+	defaultPretext  = "Rancher resource change event"
+	defaultText     = "{{.Kind}} `{{.Describe}}` @`{{.Timestamp.Format \"2006-01-02 15:04:05\"}}`{{with .Transitioning.Message}}\n_{{.}}_{{end}}"
+	defaultFallback = "{{.String}}"
+)
+
+var defaultFields = []FieldConfig{
+	{
+		Title: "State",
+		Value: "{{if and .Transition.StateChanged (not .Transition.Initial)}}`{{.PreviousState}}` → {{end}}`{{.GetState}}`",
+		Short: true,
+	},
+	{
+		Title: "Health",
+		Value: "{{if and .Transition.HealthChanged (not .Transition.Initial)}}`{{.PreviousHealthState}}` → {{end}}`{{.GetHealthState}}`",
+		Short: true,
+	},
 }
 
 type Slack struct {
@@ -47,98 +92,189 @@ type Slack struct {
 	Channel    string
 	Icon       string
 	Username   string
+
+	// Bot token enabling the Web API mode, which posts Block Kit messages
+	// and threads the notifications of a resource.
+	Token  string
+	APIURL string `toml:"api_url"`
+	// Status emojis of the parent message in Web API mode
+	UpEmoji   string `toml:"up_emoji"`
+	DownEmoji string `toml:"down_emoji"`
+
+	// Notification rules. An event triggers a notification if it matches any rule.
+	Rules []Rule
+	// Notify about events that did not change the state of the resource.
+	NotifyUnchanged bool `toml:"notify_unchanged"`
+
+	// Message colors by severity, state and health state
+	SeverityColors map[string]string `toml:"severity_colors"`
+	StateColors    map[string]string `toml:"state_colors"`
+	HealthColors   map[string]string `toml:"health_colors"`
+	DefaultColor   string            `toml:"default_color"`
+
+	// Message templates
+	Pretext  string
+	Text     string
+	Fallback string
+	Fields   []FieldConfig
+
+	// Messages per minute and burst size. Events exceeding the
+	// limit are posted as a summary once the limit allows.
+	RateLimit int `toml:"rate_limit"`
+	RateBurst int `toml:"rate_burst"`
+
+	name      string
+	templates *messageTemplates
+	api       *apiClient
+	threads   threads
+	bucket    *ratelimit.Bucket
+	overflow  *overflow
+	quitChan  chan struct{}
+	waitGroup sync.WaitGroup
 }
 
 func NewSlack() *Slack {
-	return &Slack{
-		Icon:     ":mega:",
-		Username: "rancher-eventbridge",
+	s := &Slack{
+		Icon:           ":mega:",
+		Username:       "rancher-eventbridge",
+		APIURL:         defaultAPIURL,
+		UpEmoji:        ":large_green_circle:",
+		DownEmoji:      ":red_circle:",
+		Rules:          defaultRules,
+		SeverityColors: make(map[string]string),
+		StateColors:    make(map[string]string),
+		HealthColors:   make(map[string]string),
+		DefaultColor:   defaultMsgColor,
+		Pretext:        defaultPretext,
+		Text:           defaultText,
+		Fallback:       defaultFallback,
+		Fields:         defaultFields,
+		RateLimit:      defaultRateLimit,
+		RateBurst:      defaultRateBurst,
+		name:           "slack",
 	}
+	for k, v := range defaultSeverityColors {
+		s.SeverityColors[k] = v
+	}
+	for k, v := range defaultStateColors {
+		s.StateColors[k] = v
+	}
+	for k, v := range defaultHealthColors {
+		s.HealthColors[k] = v
+	}
+	return s
 }
 
 func (s *Slack) Init() error {
-	if s.WebHookURL == "" {
-		return fmt.Errorf("Slack plugin requires the 'webhookurl' configuration parameter")
+	if s.Token != "" {
+		if s.Channel == "" {
+			return fmt.Errorf("Slack plugin requires the 'channel' configuration parameter when using a token")
+		}
+		s.api = newAPIClient(strings.TrimRight(s.APIURL, "/"), s.Token)
+		s.threads.m = make(map[string]*thread)
+	} else if s.WebHookURL == "" {
+		return fmt.Errorf("Slack plugin requires either the 'webhookurl' or the 'token' configuration parameter")
+	}
+
+	templates, err := parseTemplates(s.Pretext, s.Text, s.Fallback, s.Fields)
+	if err != nil {
+		return err
 	}
+	s.templates = templates
+
+	s.startRateLimiter()
 	return nil
 }
 
 func (s *Slack) Process(ev events.Event) error {
-	if !filterByState(ev.GetState()) {
+	if !s.notify(ev) {
 		return nil
 	}
 
-	if avail := rateLimiter.TakeAvailable(1); avail == 0 {
-		log.WithField("plugin", "slack").Warn("Dropping event. Rate limit exceeded.")
+	if !s.overflow.admit(s.bucket, ev) {
+		log.WithField("plugin", s.name).Warn("Suppressing event. Rate limit exceeded.")
 		return nil
 	}
 
-	msg := &slack.Message{
-		Username:  s.Username,
-		Channel:   s.Channel,
-		IconEmoji: s.Icon,
-	}
+	msg := s.newMessage()
 	attach := msg.NewAttachment()
-	attach.Pretext = "Rancher resource change event"
-	attach.Text = fmt.Sprintf("%s `%s` @`%s`", string(ev.Kind), ev.GetName(),
-		ev.Timestamp.Format("2006-01-02 15:04:05"))
-	attach.Fallback = ev.String()
-	attach.Color = getMessageColor(ev.GetState(), ev.GetHealthState())
 	attach.MarkdownIn = []string{"text", "fields"}
-	fields := map[string]string{
-		"State":  fmt.Sprintf("`%s`", ev.GetState()),
-		"Health": fmt.Sprintf("`%s`", ev.GetHealthState()),
+	attach.Color = s.getMessageColor(ev)
+
+	if err := s.templates.render(ev, attach); err != nil {
+		return plugins.Permanent(err)
 	}
 
-	for k, v := range fields {
-		attach.AddField(&slack.Field{
-			Title: k,
-			Value: v,
-			Short: true,
-		})
+	if s.api != nil {
+		return s.postThreaded(ev, attach)
+	}
+	return s.send(msg)
+}
+
+func (s *Slack) newMessage() *slack.Message {
+	return &slack.Message{
+		Username:  s.Username,
+		Channel:   s.Channel,
+		IconEmoji: s.Icon,
 	}
+}
 
+// send posts a message without threading.
+func (s *Slack) send(msg *slack.Message) error {
+	if s.api != nil {
+		_, err := s.api.postMessage(s.apiMessage(msg.Attachments[0], ""))
+		return err
+	}
 	c := slack.NewClient(s.WebHookURL)
 	return c.SendMessage(msg)
 }
 
-func filterByState(state events.InstanceState) bool {
-	for s, _ := range states {
-		if s == state {
+// notify returns true if the event matches a notification rule.
+func (s *Slack) notify(ev events.Event) bool {
+	if !s.NotifyUnchanged && !ev.Transition.Changed() {
+		return false
+	}
+	for _, rule := range s.Rules {
+		if rule.Match(ev) {
 			return true
 		}
 	}
 	return false
 }
 
-func getMessageColor(state events.InstanceState, health events.HealthState) (color string) {
-	if state == events.ServiceInactive || state == events.ContainerStopped {
-		if _, ok := states[state]; ok {
-			color = states[state]
-		}
+// getMessageColor returns the color of the event's severity if configured.
+// Otherwise it returns the color of the health state while the resource
+// is up and the color of its state.
+func (s *Slack) getMessageColor(ev events.Event) string {
+	if color, ok := s.SeverityColors[string(ev.Severity)]; ok {
+		return color
 	}
 
-	if state == events.ServiceActive || state == events.ContainerRunning {
-		if _, ok := healthStates[health]; ok {
-			color = healthStates[health]
-		} else if _, ok := states[state]; ok {
-			color = states[state]
+	state := ev.GetState()
+	if upStates[state] {
+		if color, ok := s.HealthColors[string(ev.GetHealthState())]; ok {
+			return color
 		}
 	}
 
-	if len(color) == 0 {
-		color = defaultMsgColor
-
+	if color, ok := s.StateColors[string(state)]; ok {
+		return color
 	}
 
-	return
+	return s.DefaultColor
 }
 
 func (s *Slack) Name() string {
-	return "Slack Webhook Plugin"
+	return "Slack Plugin"
+}
+
+// SetInstanceName implements plugins.InstanceNamer.
+func (s *Slack) SetInstanceName(name string) {
+	s.name = name
 }
 
 func (s *Slack) Close() error {
+	s.stopRateLimiter()
 	return nil
 }
 
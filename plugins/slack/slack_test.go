@@ -0,0 +1,31 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+func TestMessageColor(t *testing.T) {
+	s := NewSlack()
+	tests := []struct {
+		severity events.Severity
+		state    events.InstanceState
+		health   events.HealthState
+		want     string
+	}{
+		{events.SeverityCritical, events.ContainerRunning, events.StateUnhealthy, "#F2777A"},
+		{events.SeverityWarning, events.ContainerStopped, "", "#FFCC66"},
+		{events.SeverityResolved, events.ContainerRunning, events.StateHealthy, "#99CC99"},
+		{events.SeverityInfo, events.ContainerRunning, events.StateHealthy, "#99CC99"},
+		{events.SeverityInfo, events.ContainerStopped, "", "#CFCDC9"},
+		{"", events.ContainerRunning, events.StateUnhealthy, "#F2777A"},
+	}
+	for _, tt := range tests {
+		ev := containerEvent("1", tt.state, tt.health)
+		ev.Severity = tt.severity
+		if got := s.getMessageColor(ev); got != tt.want {
+			t.Errorf("color of %s event in state %s (%s) = %s, want %s", tt.severity, tt.state, tt.health, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package slack
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/janeczku/eventbridge/events"
+
+	"github.com/huguesalary/slack-go"
+)
+
+// FieldConfig configures a message attachment field.
+// The value is a template executed against the event.
+type FieldConfig struct {
+	Title string
+	Value string
+	Short bool
+}
+
+type fieldTemplate struct {
+	title string
+	value *template.Template
+	short bool
+}
+
+type messageTemplates struct {
+	pretext  *template.Template
+	text     *template.Template
+	fallback *template.Template
+	fields   []fieldTemplate
+}
+
+func parseTemplates(pretext, text, fallback string, fields []FieldConfig) (*messageTemplates, error) {
+	t := &messageTemplates{}
+	var err error
+	if t.pretext, err = parseTemplate("pretext", pretext); err != nil {
+		return nil, err
+	}
+	if t.text, err = parseTemplate("text", text); err != nil {
+		return nil, err
+	}
+	if t.fallback, err = parseTemplate("fallback", fallback); err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		value, err := parseTemplate("field '"+f.Title+"'", f.Value)
+		if err != nil {
+			return nil, err
+		}
+		t.fields = append(t.fields, fieldTemplate{f.Title, value, f.Short})
+	}
+	return t, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse Slack %s template: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// render executes the templates against the event and populates the attachment.
+func (t *messageTemplates) render(ev events.Event, attach *slack.Attachment) error {
+	var err error
+	if attach.Pretext, err = execute(t.pretext, ev); err != nil {
+		return err
+	}
+	if attach.Text, err = execute(t.text, ev); err != nil {
+		return err
+	}
+	if attach.Fallback, err = execute(t.fallback, ev); err != nil {
+		return err
+	}
+	for _, f := range t.fields {
+		value, err := execute(f.value, ev)
+		if err != nil {
+			return err
+		}
+		attach.AddField(&slack.Field{
+			Title: f.title,
+			Value: value,
+			Short: f.short,
+		})
+	}
+	return nil
+}
+
+func execute(tmpl *template.Template, ev events.Event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ev); err != nil {
+		return "", fmt.Errorf("Could not render Slack %s template: %v", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,132 @@
+package slack
+
+import (
+	"sync"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/huguesalary/slack-go"
+)
+
+// Threads older than this are not continued; the next event
+// of the resource starts a new thread.
+const threadMaxAge = 24 * time.Hour
+
+// health states in which a resource is considered down
+var downHealthStates = map[events.HealthState]bool{
+	events.StateUnhealthy: true,
+	events.StateDegraded:  true,
+}
+
+// thread is the parent message posted for a resource.
+type thread struct {
+	channel string
+	ts      string
+	attach  *slack.Attachment
+	up      bool
+	started time.Time
+}
+
+// threads maps resources to the thread of their notifications.
+type threads struct {
+	sync.Mutex
+	m map[string]*thread
+}
+
+func threadKey(ev events.Event) string {
+	return string(ev.Kind) + "/" + ev.GetResourceID()
+}
+
+// isUp returns true if the resource is up and not unhealthy.
+func isUp(ev events.Event) bool {
+	return upStates[ev.GetState()] && !downHealthStates[ev.GetHealthState()]
+}
+
+func (s *Slack) statusEmoji(up bool) string {
+	if up {
+		return s.UpEmoji
+	}
+	return s.DownEmoji
+}
+
+func (s *Slack) apiMessage(attach *slack.Attachment, emoji string) *apiMessage {
+	return &apiMessage{
+		Channel:   s.Channel,
+		Text:      attach.Fallback,
+		Blocks:    renderBlocks(attach, emoji),
+		Username:  s.Username,
+		IconEmoji: s.Icon,
+	}
+}
+
+// postThreaded posts the first notification of a resource as a new message
+// and follow-up notifications as replies in its thread. The status emoji
+// of the parent message is updated when the resource recovers or goes down.
+// The threads lock is not held during the API calls; events of the same
+// resource are processed sequentially by the same worker.
+func (s *Slack) postThreaded(ev events.Event, attach *slack.Attachment) error {
+	key := threadKey(ev)
+	up := isUp(ev)
+	now := time.Now()
+	state := ev.GetState()
+	gone := state == events.StateRemoved || state == events.StatePurged
+
+	s.threads.Lock()
+	for k, t := range s.threads.m {
+		if now.Sub(t.started) > threadMaxAge {
+			delete(s.threads.m, k)
+		}
+	}
+	t, ok := s.threads.m[key]
+	s.threads.Unlock()
+
+	if !ok {
+		resp, err := s.api.postMessage(s.apiMessage(attach, s.statusEmoji(up)))
+		if err != nil {
+			return err
+		}
+		if !gone {
+			s.threads.Lock()
+			s.threads.m[key] = &thread{
+				channel: resp.Channel,
+				ts:      resp.TS,
+				attach:  attach,
+				up:      up,
+				started: now,
+			}
+			s.threads.Unlock()
+		}
+		return nil
+	}
+
+	reply := s.apiMessage(attach, s.statusEmoji(up))
+	reply.ThreadTS = t.ts
+	if _, err := s.api.postMessage(reply); err != nil {
+		return err
+	}
+
+	s.threads.Lock()
+	if gone && s.threads.m[key] == t {
+		delete(s.threads.m, key)
+	}
+	changed := up != t.up
+	t.up = up
+	s.threads.Unlock()
+
+	if !changed {
+		return nil
+	}
+	parent := s.apiMessage(t.attach, s.statusEmoji(up))
+	parent.Channel = t.channel
+	parent.TS = t.ts
+	if _, err := s.api.update(parent); err != nil {
+		// the reply has been posted, retrying the event would duplicate it
+		log.WithFields(log.Fields{
+			"plugin": s.name,
+			"error":  err,
+		}).Warn("Failed to update status of parent message")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/janeczku/eventbridge/plugins"
+)
+
+const (
+	defaultAPIURL     = "https://slack.com/api"
+	defaultAPITimeout = 10 * time.Second
+)
+
+// Slack API errors that will not go away on a later attempt
+var permanentAPIErrors = map[string]bool{
+	"invalid_auth":        true,
+	"not_authed":          true,
+	"account_inactive":    true,
+	"token_revoked":       true,
+	"missing_scope":       true,
+	"channel_not_found":   true,
+	"not_in_channel":      true,
+	"is_archived":         true,
+	"invalid_blocks":      true,
+	"msg_too_long":        true,
+	"message_not_found":   true,
+	"cant_update_message": true,
+	"edit_window_closed":  true,
+}
+
+// apiClient is a minimal client of the Slack Web API.
+type apiClient struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+type apiMessage struct {
+	Channel   string  `json:"channel"`
+	TS        string  `json:"ts,omitempty"`
+	ThreadTS  string  `json:"thread_ts,omitempty"`
+	Text      string  `json:"text"`
+	Blocks    []block `json:"blocks,omitempty"`
+	Username  string  `json:"username,omitempty"`
+	IconEmoji string  `json:"icon_emoji,omitempty"`
+}
+
+type apiResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+func newAPIClient(url, token string) *apiClient {
+	return &apiClient{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: defaultAPITimeout},
+	}
+}
+
+// postMessage posts the message, as a reply if ThreadTS is set.
+func (c *apiClient) postMessage(msg *apiMessage) (*apiResponse, error) {
+	return c.call("chat.postMessage", msg)
+}
+
+// update replaces the message identified by its channel and TS.
+func (c *apiClient) update(msg *apiMessage) (*apiResponse, error) {
+	return c.call("chat.update", msg)
+}
+
+func (c *apiClient) call(method string, msg *apiMessage) (*apiResponse, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, plugins.Permanent(fmt.Errorf("Could not encode Slack message: %v", err))
+	}
+
+	req, err := http.NewRequest("POST", c.url+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Could not create Slack API request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Slack API request %s failed: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		err := fmt.Errorf("Slack API %s returned unexpected status: %s", method, resp.Status)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, plugins.Permanent(err)
+		}
+		return nil, err
+	}
+
+	var r apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("Could not decode Slack API response: %v", err)
+	}
+	if !r.OK {
+		err := fmt.Errorf("Slack API %s failed: %s", method, r.Error)
+		if permanentAPIErrors[r.Error] {
+			return nil, plugins.Permanent(err)
+		}
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,253 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/plugins"
+)
+
+type apiCall struct {
+	method string
+	auth   string
+	msg    apiMessage
+}
+
+// apiStub is a Slack Web API stub recording the calls it receives.
+type apiStub struct {
+	sync.Mutex
+	calls []apiCall
+	// error returned by the next calls of failMethod or any method, if set
+	err        string
+	failMethod string
+	status     int
+	nextTS     int
+}
+
+func newAPIStub(t *testing.T) (*apiStub, *httptest.Server) {
+	stub := &apiStub{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var msg apiMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Decoding request: %v", err)
+		}
+
+		stub.Lock()
+		defer stub.Unlock()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		stub.calls = append(stub.calls, apiCall{
+			method: method,
+			auth:   r.Header.Get("Authorization"),
+			msg:    msg,
+		})
+		if stub.status != 0 {
+			rw.WriteHeader(stub.status)
+			return
+		}
+		resp := apiResponse{OK: true, Channel: "C1", TS: msg.TS}
+		if stub.err != "" && (stub.failMethod == "" || stub.failMethod == method) {
+			resp = apiResponse{Error: stub.err}
+		}
+		if resp.TS == "" {
+			stub.nextTS++
+			resp.TS = fmt.Sprintf("1000.%d", stub.nextTS)
+		}
+		json.NewEncoder(rw).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return stub, srv
+}
+
+func (stub *apiStub) takeCalls() []apiCall {
+	stub.Lock()
+	defer stub.Unlock()
+	calls := stub.calls
+	stub.calls = nil
+	return calls
+}
+
+func newAPISlack(t *testing.T, url string, configure func(s *Slack)) *Slack {
+	s := NewSlack()
+	s.Token = "xoxb-test"
+	s.Channel = "#ops"
+	s.APIURL = url
+	s.NotifyUnchanged = true
+	s.Rules = []Rule{{}}
+	if configure != nil {
+		configure(s)
+	}
+	if err := s.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func containerEvent(id string, state events.InstanceState, health events.HealthState) events.Event {
+	ev := events.Event{ID: id, Kind: events.ContainerEvent}
+	ev.ContainerData.ID = "1i1"
+	ev.ContainerData.Name = "web-1"
+	ev.ContainerData.State = state
+	ev.ContainerData.HealthState = health
+	return ev
+}
+
+func blockText(msg apiMessage) string {
+	if len(msg.Blocks) == 0 || msg.Blocks[0].Text == nil {
+		return ""
+	}
+	return msg.Blocks[0].Text.Text
+}
+
+func TestThreadedNotifications(t *testing.T) {
+	stub, srv := newAPIStub(t)
+	s := newAPISlack(t, srv.URL, nil)
+
+	if err := s.Process(containerEvent("1", events.ContainerRunning, events.StateHealthy)); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	calls := stub.takeCalls()
+	if len(calls) != 1 || calls[0].method != "chat.postMessage" {
+		t.Fatalf("calls = %+v, want a single chat.postMessage", calls)
+	}
+	parent := calls[0]
+	if parent.auth != "Bearer xoxb-test" {
+		t.Errorf("Authorization = %q", parent.auth)
+	}
+	if parent.msg.Channel != "#ops" || parent.msg.ThreadTS != "" {
+		t.Errorf("parent message = %+v", parent.msg)
+	}
+	if !strings.HasPrefix(blockText(parent.msg), s.UpEmoji) {
+		t.Errorf("parent message %q does not start with the up emoji", blockText(parent.msg))
+	}
+
+	// going down posts a reply and updates the parent's status emoji
+	if err := s.Process(containerEvent("2", events.ContainerRunning, events.StateUnhealthy)); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	calls = stub.takeCalls()
+	if len(calls) != 2 {
+		t.Fatalf("calls = %+v, want a reply and an update", calls)
+	}
+	if calls[0].method != "chat.postMessage" || calls[0].msg.ThreadTS != "1000.1" {
+		t.Errorf("reply = %s %+v, want a reply to 1000.1", calls[0].method, calls[0].msg)
+	}
+	update := calls[1]
+	if update.method != "chat.update" || update.msg.TS != "1000.1" || update.msg.Channel != "C1" {
+		t.Errorf("update = %s %+v, want an update of 1000.1 in C1", update.method, update.msg)
+	}
+	if !strings.HasPrefix(blockText(update.msg), s.DownEmoji) {
+		t.Errorf("updated parent %q does not start with the down emoji", blockText(update.msg))
+	}
+
+	// an unchanged status only posts a reply
+	if err := s.Process(containerEvent("3", events.ContainerRunning, events.StateUnhealthy)); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if calls = stub.takeCalls(); len(calls) != 1 || calls[0].msg.ThreadTS != "1000.1" {
+		t.Errorf("calls = %+v, want a single reply", calls)
+	}
+
+	// removing the resource ends the thread
+	if err := s.Process(containerEvent("4", events.StateRemoved, "")); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	stub.takeCalls()
+	if err := s.Process(containerEvent("5", events.ContainerRunning, events.StateHealthy)); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if calls = stub.takeCalls(); len(calls) != 1 || calls[0].msg.ThreadTS != "" {
+		t.Errorf("calls = %+v, want a new parent message", calls)
+	}
+}
+
+func TestThreadedNotificationsConcurrently(t *testing.T) {
+	stub, srv := newAPIStub(t)
+	s := newAPISlack(t, srv.URL, func(s *Slack) {
+		s.RateBurst = 100
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				ev := containerEvent(fmt.Sprintf("%d-%d", i, j), events.ContainerRunning, events.StateHealthy)
+				ev.ContainerData.ID = fmt.Sprintf("1i%d", i)
+				if err := s.Process(ev); err != nil {
+					t.Errorf("Process: %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var parents int
+	for _, call := range stub.takeCalls() {
+		if call.msg.ThreadTS == "" {
+			parents++
+		}
+	}
+	if parents != 10 {
+		t.Errorf("%d parent messages, want one per resource", parents)
+	}
+}
+
+func TestAPIErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       string
+		status    int
+		retryable bool
+	}{
+		{"permanent API error", "channel_not_found", 0, false},
+		{"rate limited", "ratelimited", 0, true},
+		{"client error", "", http.StatusNotFound, false},
+		{"too many requests", "", http.StatusTooManyRequests, true},
+		{"server error", "", http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, srv := newAPIStub(t)
+			stub.err, stub.status = tt.err, tt.status
+			s := newAPISlack(t, srv.URL, nil)
+
+			err := s.Process(containerEvent("1", events.ContainerRunning, events.StateHealthy))
+			if err == nil {
+				t.Fatal("Process succeeded")
+			}
+			if plugins.IsRetryable(err) != tt.retryable {
+				t.Errorf("retryable = %v, want %v (%v)", plugins.IsRetryable(err), tt.retryable, err)
+			}
+		})
+	}
+}
+
+func TestFailedParentUpdateIsNotRetried(t *testing.T) {
+	stub, srv := newAPIStub(t)
+	s := newAPISlack(t, srv.URL, nil)
+
+	if err := s.Process(containerEvent("1", events.ContainerRunning, events.StateHealthy)); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	// let the reply succeed and the update fail
+	stub.Lock()
+	stub.err, stub.failMethod = "cant_update_message", "chat.update"
+	stub.Unlock()
+	stub.takeCalls()
+
+	if err := s.Process(containerEvent("2", events.ContainerStopped, "")); err != nil {
+		t.Errorf("Process: %v", err)
+	}
+	if calls := stub.takeCalls(); len(calls) != 2 {
+		t.Errorf("calls = %+v, want a reply and an update", calls)
+	}
+}
@@ -0,0 +1,228 @@
+// Package webhook provides a generic HTTP webhook plugin
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/plugins"
+)
+
+const (
+	Version = "0.0.1"
+)
+
+const (
+	defaultMethod      = "POST"
+	defaultContentType = "application/json"
+	defaultTimeout     = 10
+)
+
+var eventKinds = events.AllEventKinds
+
+type Webhook struct {
+	URL         string
+	Method      string
+	Headers     map[string]string
+	ContentType string `toml:"content_type"`
+	Username    string
+	Password    string
+	BearerToken string `toml:"bearer_token"`
+	Template    string
+	// Body template of batches, executed against the list of events
+	BatchTemplate string `toml:"batch_template"`
+	// Include the resource as received from Rancher in the JSON body
+	IncludeRaw bool `toml:"include_raw"`
+	Timeout    int
+
+	tmpl      *template.Template
+	batchTmpl *template.Template
+	client    *http.Client
+}
+
+func NewWebhook() *Webhook {
+	return &Webhook{
+		Method:      defaultMethod,
+		ContentType: defaultContentType,
+		Timeout:     defaultTimeout,
+	}
+}
+
+func (w *Webhook) Init() error {
+	if w.URL == "" {
+		return fmt.Errorf("Webhook plugin requires the 'url' configuration parameter")
+	}
+
+	if w.BearerToken != "" && w.Username != "" {
+		return fmt.Errorf("Webhook plugin accepts either basic auth or a bearer token, not both")
+	}
+
+	w.Method = strings.ToUpper(w.Method)
+	if w.Method == "" {
+		w.Method = defaultMethod
+	}
+
+	if w.Template != "" {
+		tmpl, err := template.New("webhook").Parse(w.Template)
+		if err != nil {
+			return fmt.Errorf("Could not parse webhook template: %v", err)
+		}
+		w.tmpl = tmpl
+	}
+
+	if w.BatchTemplate != "" {
+		tmpl, err := template.New("webhook-batch").Parse(w.BatchTemplate)
+		if err != nil {
+			return fmt.Errorf("Could not parse webhook batch template: %v", err)
+		}
+		w.batchTmpl = tmpl
+	}
+
+	w.client = &http.Client{
+		Timeout: time.Duration(w.Timeout) * time.Second,
+	}
+
+	return nil
+}
+
+func (w *Webhook) Process(ev events.Event) error {
+	body, err := w.renderBody(ev)
+	if err != nil {
+		return plugins.Permanent(err)
+	}
+	return w.send(body)
+}
+
+// ProcessBatch sends the events in a single request. The body is rendered
+// from the batch template or, if none is configured, is a JSON array of the
+// events. With only a single event template configured, the events are sent
+// one request each and the failed ones are reported in a *plugins.BatchError.
+func (w *Webhook) ProcessBatch(evs []events.Event) error {
+	if w.batchTmpl == nil && w.tmpl != nil {
+		return w.processEach(evs)
+	}
+
+	var body []byte
+	var err error
+	if w.batchTmpl != nil {
+		body, err = render(w.batchTmpl, evs)
+	} else {
+		body, err = encode(w.stripRaw(evs))
+	}
+	if err != nil {
+		return plugins.Permanent(err)
+	}
+	return w.send(body)
+}
+
+func (w *Webhook) processEach(evs []events.Event) error {
+	failed := make(map[int]error)
+	for i, ev := range evs {
+		if err := w.Process(ev); err != nil {
+			failed[i] = err
+		}
+	}
+	if len(failed) > 0 {
+		return &plugins.BatchError{Errors: failed}
+	}
+	return nil
+}
+
+func (w *Webhook) send(body []byte) error {
+	req, err := http.NewRequest(w.Method, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Could not create webhook request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", w.ContentType)
+	req.Header.Set("User-Agent", "rancher-eventbridge/"+Version)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if w.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.BearerToken)
+	} else if w.Username != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("Webhook returned unexpected status: %s", resp.Status)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return plugins.Permanent(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// renderBody executes the configured template against the event or,
+// if no template is configured, encodes the event as JSON.
+func (w *Webhook) renderBody(ev events.Event) ([]byte, error) {
+	if w.tmpl == nil {
+		if !w.IncludeRaw {
+			ev.Raw = nil
+		}
+		return encode(ev)
+	}
+	return render(w.tmpl, ev)
+}
+
+// stripRaw returns the events without their raw resources unless IncludeRaw is set.
+func (w *Webhook) stripRaw(evs []events.Event) []events.Event {
+	if w.IncludeRaw {
+		return evs
+	}
+	stripped := make([]events.Event, len(evs))
+	for i, ev := range evs {
+		ev.Raw = nil
+		stripped[i] = ev
+	}
+	return stripped
+}
+
+func encode(data interface{}) ([]byte, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("Could not encode event: %v", err)
+	}
+	return body, nil
+}
+
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("Could not render webhook template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *Webhook) Name() string {
+	return "HTTP Webhook Plugin"
+}
+
+func (w *Webhook) Close() error {
+	return nil
+}
+
+func init() {
+	plugins.Register("webhook", eventKinds, func() plugins.Plugin {
+		return NewWebhook()
+	})
+}
@@ -0,0 +1,316 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/plugins"
+)
+
+type request struct {
+	method string
+	header http.Header
+	body   string
+}
+
+// newServer returns a server responding with the given status code that
+// passes the received requests to the returned channel.
+func newServer(t *testing.T, status int) (*httptest.Server, chan request) {
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Reading request body: %v", err)
+		}
+		requests <- request{r.Method, r.Header, string(body)}
+		rw.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func newWebhook(t *testing.T, url string, configure func(w *Webhook)) *Webhook {
+	w := NewWebhook()
+	w.URL = url
+	if configure != nil {
+		configure(w)
+	}
+	if err := w.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return w
+}
+
+func testEvent() events.Event {
+	ev := events.Event{ID: "1", Kind: events.ServiceEvent}
+	ev.ServiceData.ID = "1s1"
+	ev.ServiceData.Name = "web"
+	ev.ServiceData.State = events.ServiceActive
+	ev.ServiceData.HealthState = events.StateHealthy
+	return ev
+}
+
+func TestProcessSendsJSON(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK)
+	w := newWebhook(t, srv.URL, nil)
+
+	if err := w.Process(testEvent()); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	req := <-requests
+	if req.method != "POST" {
+		t.Errorf("method = %s, want POST", req.method)
+	}
+	if ct := req.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", ct)
+	}
+	if ua := req.header.Get("User-Agent"); ua != "rancher-eventbridge/"+Version {
+		t.Errorf("User-Agent = %s", ua)
+	}
+
+	var ev events.Event
+	if err := json.Unmarshal([]byte(req.body), &ev); err != nil {
+		t.Fatalf("Decoding body: %v", err)
+	}
+	if ev.GetName() != "web" || ev.GetState() != events.ServiceActive {
+		t.Errorf("decoded event = %+v", ev)
+	}
+}
+
+func TestProcessMethodAndHeaders(t *testing.T) {
+	srv, requests := newServer(t, http.StatusNoContent)
+	w := newWebhook(t, srv.URL, func(w *Webhook) {
+		w.Method = "put"
+		w.ContentType = "text/plain"
+		w.Headers = map[string]string{"X-Source": "rancher"}
+	})
+
+	if err := w.Process(testEvent()); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	req := <-requests
+	if req.method != "PUT" {
+		t.Errorf("method = %s, want PUT", req.method)
+	}
+	if ct := req.header.Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("Content-Type = %s, want text/plain", ct)
+	}
+	if v := req.header.Get("X-Source"); v != "rancher" {
+		t.Errorf("X-Source = %s, want rancher", v)
+	}
+}
+
+func TestProcessAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(w *Webhook)
+		want      string
+	}{
+		{"none", nil, ""},
+		{"basic", func(w *Webhook) {
+			w.Username = "eventbridge"
+			w.Password = "secret"
+		}, "Basic ZXZlbnRicmlkZ2U6c2VjcmV0"},
+		{"bearer", func(w *Webhook) {
+			w.BearerToken = "token"
+		}, "Bearer token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newServer(t, http.StatusOK)
+			w := newWebhook(t, srv.URL, tt.configure)
+			if err := w.Process(testEvent()); err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if got := (<-requests).header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitRejectsBasicAuthWithBearerToken(t *testing.T) {
+	w := NewWebhook()
+	w.URL = "http://localhost"
+	w.Username = "eventbridge"
+	w.BearerToken = "token"
+	if err := w.Init(); err == nil {
+		t.Fatal("Init succeeded with basic auth and bearer token")
+	}
+}
+
+func TestProcessTemplate(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK)
+	w := newWebhook(t, srv.URL, func(w *Webhook) {
+		w.Template = `{"text": "{{.Kind}} {{.GetName}} is {{.GetState}} ({{.GetHealthState}})"}`
+	})
+
+	if err := w.Process(testEvent()); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	want := `{"text": "service web is active (healthy)"}`
+	if got := (<-requests).body; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestProcessTemplateErrorIsPermanent(t *testing.T) {
+	srv, _ := newServer(t, http.StatusOK)
+	w := newWebhook(t, srv.URL, func(w *Webhook) {
+		w.Template = `{{.Unknown}}`
+	})
+
+	err := w.Process(testEvent())
+	if err == nil {
+		t.Fatal("Process succeeded with an invalid template field")
+	}
+	if plugins.IsRetryable(err) {
+		t.Errorf("template error is retryable: %v", err)
+	}
+}
+
+func TestProcessStatusClassification(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantErr   bool
+		retryable bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusAccepted, false, false},
+		{http.StatusBadRequest, true, false},
+		{http.StatusUnauthorized, true, false},
+		{http.StatusNotFound, true, false},
+		{http.StatusTooManyRequests, true, true},
+		{http.StatusInternalServerError, true, true},
+		{http.StatusBadGateway, true, true},
+		{http.StatusServiceUnavailable, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv, _ := newServer(t, tt.status)
+			w := newWebhook(t, srv.URL, nil)
+
+			err := w.Process(testEvent())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Process error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && plugins.IsRetryable(err) != tt.retryable {
+				t.Errorf("retryable = %v, want %v", plugins.IsRetryable(err), tt.retryable)
+			}
+		})
+	}
+}
+
+func TestProcessConnectionErrorIsRetryable(t *testing.T) {
+	srv, _ := newServer(t, http.StatusOK)
+	w := newWebhook(t, srv.URL, nil)
+	srv.Close()
+
+	err := w.Process(testEvent())
+	if err == nil {
+		t.Fatal("Process succeeded against a closed server")
+	}
+	if !plugins.IsRetryable(err) {
+		t.Errorf("connection error is not retryable: %v", err)
+	}
+}
+
+func testBatch() []events.Event {
+	evs := []events.Event{testEvent(), testEvent(), testEvent()}
+	evs[1].ServiceData.Name = "db"
+	evs[2].ServiceData.Name = "cache"
+	return evs
+}
+
+func TestProcessBatchSendsJSONArray(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK)
+	w := newWebhook(t, srv.URL, nil)
+
+	if err := w.ProcessBatch(testBatch()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	var evs []events.Event
+	if err := json.Unmarshal([]byte((<-requests).body), &evs); err != nil {
+		t.Fatalf("Decoding body: %v", err)
+	}
+	if len(evs) != 3 || evs[1].GetName() != "db" {
+		t.Errorf("decoded batch = %+v", evs)
+	}
+}
+
+func TestProcessBatchTemplate(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK)
+	w := newWebhook(t, srv.URL, func(w *Webhook) {
+		w.Template = `{{.GetName}}`
+		w.BatchTemplate = `{{len .}}:{{range .}} {{.GetName}}{{end}}`
+	})
+
+	if err := w.ProcessBatch(testBatch()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got, want := (<-requests).body, "3: web db cache"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestProcessBatchWithEventTemplate(t *testing.T) {
+	requests := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- string(body)
+		if string(body) != "web" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	w := newWebhook(t, srv.URL, func(w *Webhook) {
+		w.Template = `{{.GetName}}`
+	})
+
+	err := w.ProcessBatch(testBatch())
+	berr, ok := err.(*plugins.BatchError)
+	if !ok {
+		t.Fatalf("ProcessBatch error = %v, want a *plugins.BatchError", err)
+	}
+	if len(berr.Errors) != 2 || berr.Errors[1] == nil || berr.Errors[2] == nil {
+		t.Errorf("failed events = %v, want 1 and 2", berr.Errors)
+	}
+	if len(requests) != 3 {
+		t.Errorf("%d requests, want one per event", len(requests))
+	}
+	if want := "2 events of the batch failed, event 1: "; !strings.HasPrefix(berr.Error(), want) {
+		t.Errorf("Error() = %q, want prefix %q", berr.Error(), want)
+	}
+}
+
+func TestProcessIncludesRawOnlyIfEnabled(t *testing.T) {
+	for _, include := range []bool{false, true} {
+		srv, requests := newServer(t, http.StatusOK)
+		w := newWebhook(t, srv.URL, func(w *Webhook) {
+			w.IncludeRaw = include
+		})
+		ev := testEvent()
+		ev.Raw = map[string]interface{}{"name": "web"}
+
+		if err := w.Process(ev); err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		if err := w.ProcessBatch([]events.Event{ev}); err != nil {
+			t.Fatalf("ProcessBatch: %v", err)
+		}
+		for _, body := range []string{(<-requests).body, (<-requests).body} {
+			if got := strings.Contains(body, `"raw"`); got != include {
+				t.Errorf("include_raw = %v, body contains raw: %v", include, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package severity assigns a severity to every event before it is
+// passed to the plugins.
+//
+// Events are classified by events.Classify first. Rules configured in
+// [[severity]] sections then override the default: the severity of the
+// first rule whose filter expression matches the event is used. Rule
+// filters can refer to the default with the severity field.
+package severity
+
+import (
+	"fmt"
+
+	"github.com/janeczku/eventbridge/events"
+	"github.com/janeczku/eventbridge/filter"
+)
+
+// RuleConfig is a [[severity]] section of the config file.
+type RuleConfig struct {
+	Filter string `toml:"filter"`
+	Level  string `toml:"level"`
+}
+
+type rule struct {
+	filter *filter.Filter
+	level  events.Severity
+}
+
+// Classifier sets the severity of events.
+type Classifier struct {
+	rules []rule
+}
+
+// New compiles the given rules into a Classifier.
+func New(configs []RuleConfig) (*Classifier, error) {
+	c := &Classifier{}
+	for i, conf := range configs {
+		f, err := filter.Compile(conf.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid severity rule %d: %v", i+1, err)
+		}
+		level, err := events.ParseSeverity(conf.Level)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid severity rule %d: %v", i+1, err)
+		}
+		c.rules = append(c.rules, rule{f, level})
+	}
+	return c, nil
+}
+
+// Classify sets the severity of the event. A nil Classifier applies
+// the default classification only.
+func (c *Classifier) Classify(ev *events.Event) {
+	ev.Severity = events.Classify(*ev)
+	if c == nil {
+		return
+	}
+	for _, r := range c.rules {
+		if r.filter.Match(*ev) {
+			ev.Severity = r.level
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package severity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/janeczku/eventbridge/events"
+)
+
+func containerEvent(t *testing.T, name string, state events.InstanceState, health events.HealthState) events.Event {
+	ev, err := events.New("ev-1", events.ContainerEvent, map[string]interface{}{
+		"name":        name,
+		"state":       string(state),
+		"healthState": string(health),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ev.PreviousState = "running"
+	ev.PreviousHealthState = events.StateHealthy
+	ev.Transition = events.Transition{
+		StateChanged:  state != ev.PreviousState,
+		HealthChanged: health != ev.PreviousHealthState,
+	}
+	return ev
+}
+
+func TestClassifyFirstMatchingRuleWins(t *testing.T) {
+	c, err := New([]RuleConfig{
+		{Filter: `name == "batch-job" && state == "stopped"`, Level: "info"},
+		{Filter: `severity == "warning"`, Level: "critical"},
+		{Filter: `name == "batch-job"`, Level: "warning"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ev   events.Event
+		want events.Severity
+	}{
+		{"first rule overrides the default", containerEvent(t, "batch-job", "stopped", ""), events.SeverityInfo},
+		{"rule matching the default severity", containerEvent(t, "web", "stopped", ""), events.SeverityCritical},
+		{"later rule", containerEvent(t, "batch-job", "running", events.StateHealthy), events.SeverityWarning},
+		{"no matching rule keeps the default", containerEvent(t, "web", "running", events.StateUnhealthy), events.SeverityCritical},
+		{"no matching rule with info default", containerEvent(t, "web", "running", events.StateHealthy), events.SeverityInfo},
+	}
+
+	for _, tt := range tests {
+		ev := tt.ev
+		c.Classify(&ev)
+		if ev.Severity != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, ev.Severity, tt.want)
+		}
+	}
+}
+
+func TestNilClassifierAppliesDefault(t *testing.T) {
+	var c *Classifier
+	ev := containerEvent(t, "web", "stopped", "")
+	c.Classify(&ev)
+	if ev.Severity != events.SeverityWarning {
+		t.Errorf("got %s, want %s", ev.Severity, events.SeverityWarning)
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		rule RuleConfig
+		err  string
+	}{
+		{RuleConfig{Filter: `name ==`, Level: "info"}, "Invalid severity rule 1"},
+		{RuleConfig{Filter: `changed`, Level: "fatal"}, "Unknown severity 'fatal'"},
+		{RuleConfig{Level: "info"}, "Empty filter expression"},
+	}
+	for _, tt := range tests {
+		_, err := New([]RuleConfig{tt.rule})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("New(%+v): error %v, want %q", tt.rule, err, tt.err)
+		}
+	}
+}